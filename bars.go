@@ -0,0 +1,337 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bar is an OHLCV bar for one interval, built by a BarAggregator.
+type Bar struct {
+	Symbol string
+	// Start and End bound the interval of the bar, [Start, End), in
+	// America/New_York.
+	Start  time.Time
+	End    time.Time
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume int
+	// Count is the number of trades or quotes in the bar. Bars that fill a
+	// gap have a Count of zero.
+	Count int
+	// Final is true once the bar's interval has closed and the bar won't
+	// change.
+	Final bool
+}
+
+// DataPoint returns the bar as a HistoricalDataPoint, like those returned by
+// IntradayHistoricalByDay.
+func (b Bar) DataPoint() HistoricalDataPoint {
+	return HistoricalDataPoint{
+		Symbol:  b.Symbol,
+		Date:    Date(time.Date(b.Start.Year(), b.Start.Month(), b.Start.Day(), 0, 0, 0, 0, time.UTC)),
+		Minute:  b.Start.Format("15:04"),
+		Label:   b.Start.Format("3:04 PM"),
+		Open:    b.Open,
+		High:    b.High,
+		Low:     b.Low,
+		Close:   b.Close,
+		Volume:  float64(b.Volume),
+		UOpen:   b.Open,
+		UHigh:   b.High,
+		ULow:    b.Low,
+		UClose:  b.Close,
+		UVolume: b.Volume,
+	}
+}
+
+// BarOption applies an option to a BarAggregator.
+type BarOption func(*BarAggregator)
+
+// WithProvisionalBars makes a BarAggregator emit the in-progress bar, with
+// Final false, each time it is updated.
+func WithProvisionalBars() BarOption {
+	return func(a *BarAggregator) {
+		a.provisional = true
+	}
+}
+
+// WithGapFill makes a BarAggregator emit a bar for each interval without
+// trades or quotes between two bars of the same session. The bar's open,
+// high, low, and close are the previous bar's close, and its volume is zero.
+func WithGapFill() BarOption {
+	return func(a *BarAggregator) {
+		a.fillGaps = true
+	}
+}
+
+// WithRegularHoursOnly makes a BarAggregator ignore trades and quotes outside
+// the regular session, from 9:30 to 16:00 in America/New_York.
+func WithRegularHoursOnly() BarOption {
+	return func(a *BarAggregator) {
+		a.regularOnly = true
+	}
+}
+
+// session boundaries, as seconds after midnight in America/New_York.
+const (
+	sessionOpen  = 9*3600 + 30*60
+	sessionClose = 16 * 3600
+)
+
+// BarAggregator builds OHLCV bars for a fixed interval from trades and
+// quotes. Bars are aligned to the exchange clock in America/New_York and
+// never span the open or close of the regular session, or midnight, so an
+// interval that doesn't divide a session evenly ends it with a shorter bar.
+// Trades and quotes for a bar that is already final are ignored.
+//
+// A bar is final once a trade or quote for a later interval is added, or its
+// interval has closed when Advance is called. It is safe for concurrent use.
+//
+// Bars are emitted one at a time in the order they were made. The emit
+// callback may call back into the aggregator; the bars this makes are
+// emitted once the callback returns. When several goroutines add trades or
+// quotes at once, a call may return before its bars are emitted, since the
+// goroutine that is emitting emits them.
+type BarAggregator struct {
+	interval    time.Duration
+	loc         *time.Location
+	emit        func(Bar)
+	provisional bool
+	fillGaps    bool
+	regularOnly bool
+
+	mu      sync.Mutex
+	symbols map[string]*barState
+	// queue holds the bars waiting to be emitted, and emitting is true while
+	// a goroutine is emitting them.
+	queue    []Bar
+	emitting bool
+}
+
+type barState struct {
+	bar     Bar
+	open    bool // whether bar is in progress
+	hasBar  bool // whether there has been a bar
+	lastVol int  // latest volume of the previous quote
+}
+
+// NewBarAggregator returns a BarAggregator that calls emit with each bar. The
+// interval must be a whole number of seconds and at most a day.
+func NewBarAggregator(interval time.Duration, emit func(Bar), opts ...BarOption) (*BarAggregator, error) {
+	if interval < time.Second || interval > 24*time.Hour || interval%time.Second != 0 {
+		return nil, fmt.Errorf("invalid bar interval %s", interval)
+	}
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return nil, err
+	}
+	a := &BarAggregator{
+		interval: interval,
+		loc:      loc,
+		emit:     emit,
+		symbols:  make(map[string]*barState),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a, nil
+}
+
+// bounds returns the interval of the bar containing t, and the start of the
+// session or the part of the day outside the session containing t.
+func (a *BarAggregator) bounds(t time.Time) (start, end, segment time.Time) {
+	t = t.In(a.loc)
+	y, m, d := t.Date()
+	secs := t.Hour()*3600 + t.Minute()*60 + t.Second()
+	segStart, segEnd := 0, sessionOpen
+	switch {
+	case secs >= sessionClose:
+		segStart, segEnd = sessionClose, 24*3600
+	case secs >= sessionOpen:
+		segStart, segEnd = sessionOpen, sessionClose
+	}
+	iv := int(a.interval / time.Second)
+	s := segStart + (secs-segStart)/iv*iv
+	e := s + iv
+	if e > segEnd {
+		e = segEnd
+	}
+	// time.Date normalizes the seconds on the wall clock, which keeps bars
+	// aligned across daylight saving time changes.
+	return time.Date(y, m, d, 0, 0, s, 0, a.loc),
+		time.Date(y, m, d, 0, 0, e, 0, a.loc),
+		time.Date(y, m, d, 0, 0, segStart, 0, a.loc)
+}
+
+// regular reports whether t is in the regular session.
+func (a *BarAggregator) regular(t time.Time) bool {
+	t = t.In(a.loc)
+	secs := t.Hour()*3600 + t.Minute()*60 + t.Second()
+	return secs >= sessionOpen && secs < sessionClose
+}
+
+// AddTrade adds a trade for the symbol, such as one received from
+// StreamDEEPTrades.
+func (a *BarAggregator) AddTrade(symbol string, trade Trade) {
+	t := time.Time(trade.Timestamp)
+	if a.regularOnly && trade.IsOutsideRegularHours {
+		return
+	}
+	a.add(strings.ToUpper(symbol), t, trade.Price, trade.Size, false)
+}
+
+// AddQuote adds a quote, using its latest price and update time. The volume
+// is the increase in the quote's latest volume since the previous quote for
+// the symbol.
+func (a *BarAggregator) AddQuote(q Quote) {
+	a.add(strings.ToUpper(q.Symbol), time.Time(q.LatestUpdate), q.LatestPrice, q.LatestVolume, true)
+}
+
+func (a *BarAggregator) add(symbol string, t time.Time, price float64, size int, cumulative bool) {
+	if t.IsZero() || price == 0 || (a.regularOnly && !a.regular(t)) {
+		return
+	}
+	a.mu.Lock()
+	st, ok := a.symbols[symbol]
+	if !ok {
+		st = &barState{}
+		a.symbols[symbol] = st
+	}
+	if cumulative {
+		vol := size
+		size = 0
+		// The latest volume starts again from zero each day.
+		if st.lastVol > 0 && vol >= st.lastVol {
+			size = vol - st.lastVol
+		}
+		st.lastVol = vol
+	}
+	var bars []Bar
+	start, end, _ := a.bounds(t)
+	switch {
+	case st.open && start.Equal(st.bar.Start):
+	case st.hasBar && start.Before(st.bar.End):
+		// The bar is already final.
+		a.mu.Unlock()
+		return
+	default:
+		if st.open {
+			bars = append(bars, a.finalize(st))
+		}
+		if a.fillGaps && st.hasBar {
+			bars = append(bars, a.gap(symbol, st.bar, start)...)
+		}
+		st.bar = Bar{Symbol: symbol, Start: start, End: end, Open: price, High: price, Low: price}
+		st.open, st.hasBar = true, true
+	}
+	b := &st.bar
+	b.High = max(b.High, price)
+	b.Low = min(b.Low, price)
+	b.Close = price
+	b.Volume += size
+	b.Count++
+	if a.provisional {
+		bars = append(bars, *b)
+	}
+	a.queue = append(a.queue, bars...)
+	a.mu.Unlock()
+	a.drain()
+}
+
+// finalize ends the in-progress bar of a symbol. The lock must be held.
+func (a *BarAggregator) finalize(st *barState) Bar {
+	st.open = false
+	st.bar.Final = true
+	return st.bar
+}
+
+// gap returns the bars that fill the gap between the previous bar and the
+// bar starting at start, if they are in the same session. The lock must be
+// held.
+func (a *BarAggregator) gap(symbol string, prev Bar, start time.Time) []Bar {
+	var bars []Bar
+	_, _, segment := a.bounds(prev.Start)
+	for s := prev.End; s.Before(start); {
+		bs, be, seg := a.bounds(s)
+		if !seg.Equal(segment) {
+			// The rest of the gap is in another session.
+			return bars
+		}
+		bars = append(bars, Bar{
+			Symbol: symbol,
+			Start:  bs,
+			End:    be,
+			Open:   prev.Close,
+			High:   prev.Close,
+			Low:    prev.Close,
+			Close:  prev.Close,
+			Final:  true,
+		})
+		s = be
+	}
+	return bars
+}
+
+// Advance finalizes the in-progress bars whose interval has closed at now.
+// Call it periodically, such as from a time.Ticker, to finalize bars when an
+// interval closes instead of when the next trade or quote arrives.
+func (a *BarAggregator) Advance(now time.Time) {
+	a.flush(func(b Bar) bool { return !now.Before(b.End) })
+}
+
+// Flush finalizes every in-progress bar, such as at the end of a session or
+// when the stream ends.
+func (a *BarAggregator) Flush() {
+	a.flush(func(Bar) bool { return true })
+}
+
+func (a *BarAggregator) flush(done func(Bar) bool) {
+	a.mu.Lock()
+	for _, st := range a.symbols {
+		if st.open && done(st.bar) {
+			a.queue = append(a.queue, a.finalize(st))
+		}
+	}
+	a.mu.Unlock()
+	a.drain()
+}
+
+// drain emits the queued bars unless another goroutine, or a callback further
+// up the stack, is already emitting them.
+func (a *BarAggregator) drain() {
+	a.mu.Lock()
+	if a.emitting {
+		a.mu.Unlock()
+		return
+	}
+	a.emitting = true
+	for len(a.queue) > 0 {
+		bar := a.queue[0]
+		a.queue = a.queue[1:]
+		a.mu.Unlock()
+		a.emit(bar)
+		a.mu.Lock()
+	}
+	a.emitting = false
+	a.mu.Unlock()
+}
+
+// Bar returns the in-progress bar of the symbol, or false if there isn't one.
+func (a *BarAggregator) Bar(symbol string) (Bar, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	st, ok := a.symbols[strings.ToUpper(symbol)]
+	if !ok || !st.open {
+		return Bar{}, false
+	}
+	return st.bar, true
+}
@@ -0,0 +1,243 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+var newYork, _ = time.LoadLocation("America/New_York")
+
+// nyTime returns a time on 2024-03-01 in America/New_York.
+func nyTime(hour, min, sec int) time.Time {
+	return time.Date(2024, 3, 1, hour, min, sec, 0, newYork)
+}
+
+func testTrade(t time.Time, price float64, size int) Trade {
+	return Trade{Price: price, Size: size, Timestamp: EpochTime(t)}
+}
+
+// barString formats a bar for comparison.
+func barString(b Bar) string {
+	return fmt.Sprintf("%s-%s %g/%g/%g/%g %d %t",
+		b.Start.Format("15:04:05"), b.End.Format("15:04:05"),
+		b.Open, b.High, b.Low, b.Close, b.Volume, b.Final)
+}
+
+func TestBarAggregator(t *testing.T) {
+	testCases := []struct {
+		name     string
+		interval time.Duration
+		opts     []BarOption
+		trades   []Trade
+		advance  time.Time
+		want     []string
+	}{
+		{
+			name:     "finalize on next interval and advance",
+			interval: time.Minute,
+			trades: []Trade{
+				testTrade(nyTime(9, 30, 5), 10, 100),
+				testTrade(nyTime(9, 30, 40), 12, 50),
+				testTrade(nyTime(9, 30, 50), 9, 10),
+				testTrade(nyTime(9, 31, 10), 11, 5),
+				// Late trade for a final bar.
+				testTrade(nyTime(9, 30, 59), 20, 5),
+			},
+			advance: nyTime(9, 32, 0),
+			want: []string{
+				"09:30:00-09:31:00 10/12/9/9 160 true",
+				"09:31:00-09:32:00 11/11/11/11 5 true",
+			},
+		},
+		{
+			name:     "session boundaries",
+			interval: time.Hour,
+			trades: []Trade{
+				testTrade(nyTime(9, 15, 0), 1, 1),
+				testTrade(nyTime(9, 45, 0), 2, 1),
+				testTrade(nyTime(15, 45, 0), 3, 1),
+				testTrade(nyTime(16, 5, 0), 4, 1),
+			},
+			want: []string{
+				"09:00:00-09:30:00 1/1/1/1 1 true",
+				"09:30:00-10:30:00 2/2/2/2 1 true",
+				"15:30:00-16:00:00 3/3/3/3 1 true",
+			},
+		},
+		{
+			name:     "gap fill",
+			interval: time.Minute,
+			opts:     []BarOption{WithGapFill()},
+			trades: []Trade{
+				testTrade(nyTime(9, 30, 10), 10, 1),
+				testTrade(nyTime(9, 33, 10), 11, 1),
+			},
+			advance: nyTime(9, 34, 0),
+			want: []string{
+				"09:30:00-09:31:00 10/10/10/10 1 true",
+				"09:31:00-09:32:00 10/10/10/10 0 true",
+				"09:32:00-09:33:00 10/10/10/10 0 true",
+				"09:33:00-09:34:00 11/11/11/11 1 true",
+			},
+		},
+		{
+			name:     "no gap fill after the close",
+			interval: time.Minute,
+			opts:     []BarOption{WithGapFill()},
+			trades: []Trade{
+				testTrade(nyTime(15, 59, 30), 12, 1),
+				testTrade(nyTime(16, 2, 0), 13, 1),
+			},
+			want: []string{
+				"15:59:00-16:00:00 12/12/12/12 1 true",
+			},
+		},
+		{
+			name:     "provisional",
+			interval: 5 * time.Second,
+			opts:     []BarOption{WithProvisionalBars()},
+			trades: []Trade{
+				testTrade(nyTime(9, 30, 1), 10, 1),
+				testTrade(nyTime(9, 30, 2), 11, 2),
+				testTrade(nyTime(9, 30, 6), 12, 3),
+			},
+			want: []string{
+				"09:30:00-09:30:05 10/10/10/10 1 false",
+				"09:30:00-09:30:05 10/11/10/11 3 false",
+				"09:30:00-09:30:05 10/11/10/11 3 true",
+				"09:30:05-09:30:10 12/12/12/12 3 false",
+			},
+		},
+		{
+			name:     "regular hours only",
+			interval: time.Minute,
+			opts:     []BarOption{WithRegularHoursOnly()},
+			trades: []Trade{
+				testTrade(nyTime(9, 29, 59), 1, 1),
+				testTrade(nyTime(9, 30, 0), 2, 1),
+				{Price: 3, Size: 1, Timestamp: EpochTime(nyTime(9, 30, 1)), IsOutsideRegularHours: true},
+				testTrade(nyTime(16, 0, 0), 4, 1),
+			},
+			advance: nyTime(16, 0, 0),
+			want: []string{
+				"09:30:00-09:31:00 2/2/2/2 1 true",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			a, err := NewBarAggregator(tc.interval, func(b Bar) {
+				got = append(got, barString(b))
+			}, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, trade := range tc.trades {
+				a.AddTrade("aapl", trade)
+			}
+			if !tc.advance.IsZero() {
+				a.Advance(tc.advance)
+			}
+			if diff := deep.Equal(got, tc.want); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestBarAggregatorQuotes(t *testing.T) {
+	var bars []Bar
+	a, err := NewBarAggregator(time.Minute, func(b Bar) { bars = append(bars, b) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []Quote{
+		{Symbol: "AAPL", LatestPrice: 10, LatestVolume: 1000, LatestUpdate: EpochTime(nyTime(9, 30, 1))},
+		{Symbol: "AAPL", LatestPrice: 11, LatestVolume: 1200, LatestUpdate: EpochTime(nyTime(9, 30, 2))},
+		{Symbol: "AAPL", LatestPrice: 12, LatestVolume: 1500, LatestUpdate: EpochTime(nyTime(9, 30, 3))},
+	} {
+		a.AddQuote(q)
+	}
+	bar, ok := a.Bar("aapl")
+	if !ok {
+		t.Fatal("Got no in-progress bar, want one")
+	}
+	if bar.Volume != 500 || bar.Count != 3 || bar.Final {
+		t.Errorf("Got bar %+v, want volume 500, count 3, and not final", bar)
+	}
+	a.Flush()
+	if len(bars) != 1 || !bars[0].Final {
+		t.Fatalf("Got bars %v, want one final bar", bars)
+	}
+	if _, ok := a.Bar("aapl"); ok {
+		t.Error("Got in-progress bar after Flush, want none")
+	}
+
+	p := bars[0].DataPoint()
+	if p.Date.String() != "2024-03-01" || p.Minute != "09:30" || p.Volume != 500 || p.Close != 12 {
+		t.Errorf("Got data point %+v", p)
+	}
+	if got, want := p.Time(), time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Got time %v, want %v", got, want)
+	}
+}
+
+func TestBarAggregatorDaylightSaving(t *testing.T) {
+	var bars []Bar
+	a, err := NewBarAggregator(time.Hour, func(b Bar) { bars = append(bars, b) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Daylight saving time started on 2024-03-10.
+	a.AddTrade("AAPL", testTrade(time.Date(2024, 3, 10, 10, 0, 0, 0, newYork), 1, 1))
+	a.Flush()
+	if len(bars) != 1 {
+		t.Fatalf("Got %d bars, want 1", len(bars))
+	}
+	if got := bars[0].Start.Format("15:04 MST"); got != "09:30 EDT" {
+		t.Errorf("Got start %s, want 09:30 EDT", got)
+	}
+}
+
+func TestBarAggregatorReentrantEmit(t *testing.T) {
+	var a *BarAggregator
+	var bars []string
+	a, err := NewBarAggregator(time.Minute, func(b Bar) {
+		bars = append(bars, barString(b))
+		// Calling back into the aggregator doesn't deadlock, and the bars it
+		// makes are emitted after this one.
+		if b.Start.Equal(nyTime(9, 30, 0)) {
+			a.Bar("AAPL")
+			a.Flush()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.AddTrade("aapl", testTrade(nyTime(9, 30, 5), 10, 100))
+	a.AddTrade("aapl", testTrade(nyTime(9, 31, 5), 11, 50))
+	want := []string{
+		"09:30:00-09:31:00 10/10/10/10 100 true",
+		"09:31:00-09:32:00 11/11/11/11 50 true",
+	}
+	if diff := deep.Equal(bars, want); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestNewBarAggregatorInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, time.Millisecond, 1500 * time.Millisecond, 25 * time.Hour} {
+		if _, err := NewBarAggregator(interval, func(Bar) {}); err == nil {
+			t.Errorf("Got nil error for interval %s, want error", interval)
+		}
+	}
+}
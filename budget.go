@@ -0,0 +1,262 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExceeded is matched by a BudgetError using errors.Is.
+var ErrBudgetExceeded = errors.New("iex: credit budget exceeded")
+
+// BudgetPeriod indicates the period over which a credit budget applies.
+type BudgetPeriod string
+
+// Available budget periods.
+const (
+	DailyBudget   BudgetPeriod = "daily"
+	MonthlyBudget BudgetPeriod = "monthly"
+)
+
+// CreditBudget configures a client-side ceiling on message usage. Usage is
+// tracked from the message accounting headers of every response.
+type CreditBudget struct {
+	// DailyLimit is the number of messages allowed per day. Zero means no
+	// daily limit.
+	DailyLimit int
+	// MonthlyLimit is the number of messages allowed per month. Zero means no
+	// monthly limit.
+	MonthlyLimit int
+	// Thresholds are the fractions of a limit at which OnThreshold is called.
+	// If nil, 0.8 and 0.95 are used.
+	Thresholds []float64
+	// OnThreshold, if set, is called once per period each time usage crosses
+	// one of the thresholds.
+	OnThreshold func(BudgetAlert)
+	// Location determines when days and months begin. If nil, UTC is used.
+	Location *time.Location
+}
+
+// BudgetAlert models a soft threshold of a credit budget being crossed.
+type BudgetAlert struct {
+	Period    BudgetPeriod
+	Threshold float64
+	Used      int
+	Limit     int
+}
+
+// BudgetError is returned, without making a request, once usage has reached
+// the limit for a budget period.
+type BudgetError struct {
+	Period BudgetPeriod
+	Used   int
+	Limit  int
+}
+
+// Error implements the error interface.
+func (e BudgetError) Error() string {
+	return fmt.Sprintf("%s credit budget exceeded: %d of %d messages used", e.Period, e.Used, e.Limit)
+}
+
+// Is reports whether the target is ErrBudgetExceeded.
+func (e BudgetError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// WithCreditBudget sets a credit budget for a new IEX Client. Call
+// Client.SyncBudget to seed the budget with the usage already reported by IEX
+// Cloud.
+func WithCreditBudget(budget CreditBudget) ClientOption {
+	return func(client *Client) {
+		client.budget = newBudgetTracker(budget)
+	}
+}
+
+// budgetTracker tracks usage against a CreditBudget. It is shared by copies
+// of a Client.
+type budgetTracker struct {
+	cfg         CreditBudget
+	mu          sync.Mutex
+	day         string
+	month       string
+	dailyUsed   int
+	monthlyUsed int
+	fired       map[BudgetPeriod]int
+}
+
+func newBudgetTracker(cfg CreditBudget) *budgetTracker {
+	if cfg.Thresholds == nil {
+		cfg.Thresholds = []float64{0.8, 0.95}
+	}
+	cfg.Thresholds = append([]float64(nil), cfg.Thresholds...)
+	sort.Float64s(cfg.Thresholds)
+	if cfg.Location == nil {
+		cfg.Location = time.UTC
+	}
+	return &budgetTracker{cfg: cfg, fired: map[BudgetPeriod]int{}}
+}
+
+// roll resets the usage counters when a new day or month begins. The caller
+// must hold the lock.
+func (b *budgetTracker) roll(now time.Time) {
+	now = now.In(b.cfg.Location)
+	if day := now.Format("20060102"); day != b.day {
+		b.day = day
+		b.dailyUsed = 0
+		b.fired[DailyBudget] = 0
+	}
+	if month := now.Format("200601"); month != b.month {
+		b.month = month
+		b.monthlyUsed = 0
+		b.fired[MonthlyBudget] = 0
+	}
+}
+
+// check returns a BudgetError if either limit has been reached. It is a no-op
+// on a nil budgetTracker.
+func (b *budgetTracker) check(now time.Time) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll(now)
+	if b.cfg.DailyLimit > 0 && b.dailyUsed >= b.cfg.DailyLimit {
+		return BudgetError{Period: DailyBudget, Used: b.dailyUsed, Limit: b.cfg.DailyLimit}
+	}
+	if b.cfg.MonthlyLimit > 0 && b.monthlyUsed >= b.cfg.MonthlyLimit {
+		return BudgetError{Period: MonthlyBudget, Used: b.monthlyUsed, Limit: b.cfg.MonthlyLimit}
+	}
+	return nil
+}
+
+// add records the messages used and calls OnThreshold for any thresholds that
+// were crossed. It is a no-op on a nil budgetTracker.
+func (b *budgetTracker) add(messages int, now time.Time) {
+	if b == nil || messages <= 0 {
+		return
+	}
+	b.mu.Lock()
+	b.roll(now)
+	b.dailyUsed += messages
+	b.monthlyUsed += messages
+	alerts := b.alerts()
+	b.mu.Unlock()
+	b.notify(alerts)
+}
+
+// set replaces the usage counters with the given values. It is a no-op on a
+// nil budgetTracker.
+func (b *budgetTracker) set(daily, monthly int, now time.Time) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.roll(now)
+	b.dailyUsed = daily
+	b.monthlyUsed = monthly
+	alerts := b.alerts()
+	b.mu.Unlock()
+	b.notify(alerts)
+}
+
+// alerts returns the thresholds newly crossed by the current usage. The
+// caller must hold the lock.
+func (b *budgetTracker) alerts() []BudgetAlert {
+	var alerts []BudgetAlert
+	for _, p := range []struct {
+		period BudgetPeriod
+		used   int
+		limit  int
+	}{
+		{DailyBudget, b.dailyUsed, b.cfg.DailyLimit},
+		{MonthlyBudget, b.monthlyUsed, b.cfg.MonthlyLimit},
+	} {
+		if p.limit <= 0 {
+			continue
+		}
+		for i := b.fired[p.period]; i < len(b.cfg.Thresholds); i++ {
+			threshold := b.cfg.Thresholds[i]
+			if float64(p.used) < threshold*float64(p.limit) {
+				break
+			}
+			alerts = append(alerts, BudgetAlert{
+				Period:    p.period,
+				Threshold: threshold,
+				Used:      p.used,
+				Limit:     p.limit,
+			})
+			b.fired[p.period] = i + 1
+		}
+	}
+	return alerts
+}
+
+func (b *budgetTracker) notify(alerts []BudgetAlert) {
+	if b.cfg.OnThreshold == nil {
+		return
+	}
+	for _, a := range alerts {
+		b.cfg.OnThreshold(a)
+	}
+}
+
+// used returns the daily and monthly usage.
+func (b *budgetTracker) used(now time.Time) (daily, monthly int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll(now)
+	return b.dailyUsed, b.monthlyUsed
+}
+
+type budgetBypassKey struct{}
+
+// SyncBudget seeds the client's credit budget with the current day and month
+// usage reported by the Usage endpoint. The request to the Usage endpoint is
+// not refused if the budget has already been exceeded.
+func (c *Client) SyncBudget(ctx context.Context) error {
+	if c.budget == nil {
+		return errors.New("client has no credit budget")
+	}
+	u, err := c.Usage(context.WithValue(ctx, budgetBypassKey{}, true))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	today := now.In(c.budget.cfg.Location).Format("20060102")
+	c.budget.set(u.DailyUsage[today], u.MonthlyUsage, now)
+	return nil
+}
+
+// BudgetUsed returns the number of messages counted against the client's
+// credit budget for the current day and month.
+func (c *Client) BudgetUsed() (daily, monthly int) {
+	if c.budget == nil {
+		return 0, 0
+	}
+	return c.budget.used(time.Now())
+}
+
+// budgetMiddleware refuses requests once the client's credit budget has been
+// exceeded, unless the context bypasses the budget.
+func (c *Client) budgetMiddleware(next Handler) Handler {
+	if c.budget == nil {
+		return next
+	}
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		if bypass, _ := ctx.Value(budgetBypassKey{}).(bool); !bypass {
+			if err := c.budget.check(time.Now()); err != nil {
+				return nil, err
+			}
+		}
+		return next(ctx, req)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestCreditBudget(t *testing.T) {
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path == "/account/usage" {
+			today := time.Now().UTC().Format("20060102")
+			fmt.Fprintf(w, `{"monthlyUsage": 1000, "dailyUsage": {%q: 70}}`, today)
+			return
+		}
+		w.Header().Set("iexcloud-messages-used", "10")
+		w.Write([]byte(`{"symbol": "AAPL"}`))
+	}))
+	defer s.Close()
+
+	var alerts []BudgetAlert
+	client := NewClient(testToken, withBaseAddress(s), WithCreditBudget(CreditBudget{
+		DailyLimit:   100,
+		MonthlyLimit: 10000,
+		OnThreshold:  func(a BudgetAlert) { alerts = append(alerts, a) },
+	}))
+
+	if err := client.SyncBudget(context.TODO()); err != nil {
+		t.Fatalf("Error syncing budget: %s", err)
+	}
+	daily, monthly := client.BudgetUsed()
+	if daily != 70 || monthly != 1000 {
+		t.Errorf("Got usage %d/%d, want 70/1000", daily, monthly)
+	}
+
+	// Three quotes use 30 messages and reach the daily limit.
+	for i := 0; i < 3; i++ {
+		if _, err := client.Quote(context.TODO(), "aapl"); err != nil {
+			t.Fatalf("Error getting quote %d: %s", i, err)
+		}
+	}
+	wantAlerts := []BudgetAlert{
+		{Period: DailyBudget, Threshold: 0.8, Used: 80, Limit: 100},
+		{Period: DailyBudget, Threshold: 0.95, Used: 100, Limit: 100},
+	}
+	if diff := deep.Equal(alerts, wantAlerts); diff != nil {
+		t.Errorf("Got unexpected alerts:\n%s", diff)
+	}
+
+	before := atomic.LoadInt32(&requests)
+	_, err := client.Quote(context.TODO(), "aapl")
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Got error %v, want %v", err, ErrBudgetExceeded)
+	}
+	var be BudgetError
+	if !errors.As(err, &be) || be.Period != DailyBudget {
+		t.Errorf("Got error %#v, want daily BudgetError", err)
+	}
+	if got := atomic.LoadInt32(&requests); got != before {
+		t.Errorf("Got %d requests after exceeding budget, want none", got-before)
+	}
+}
+
+func TestBudgetRollsOver(t *testing.T) {
+	b := newBudgetTracker(CreditBudget{DailyLimit: 10, MonthlyLimit: 15})
+	day1 := time.Date(2021, 8, 31, 12, 0, 0, 0, time.UTC)
+	b.add(10, day1)
+	if err := b.check(day1); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Got error %v, want %v", err, ErrBudgetExceeded)
+	}
+	day2 := day1.Add(24 * time.Hour)
+	if err := b.check(day2); err != nil {
+		t.Errorf("Got error %v on a new day and month, want nil", err)
+	}
+	b.add(10, day2)
+	b.add(10, day2.Add(24*time.Hour))
+	if err := b.check(day2.Add(24 * time.Hour)); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Got error %v, want monthly %v", err, ErrBudgetExceeded)
+	}
+}
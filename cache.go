@@ -0,0 +1,272 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Cache stores responses from the IEX Cloud API. Keys never contain the token,
+// only a hash of it for requests made with another account's token.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the value stored for the key, if it exists and has not
+	// expired.
+	Get(key string) ([]byte, bool)
+	// Set stores the value for the key until the TTL elapses.
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+// DefaultCacheTTLs are the cache TTLs for endpoint families whose data
+// changes rarely. Responses from endpoint families that are not listed are
+// not cached unless a TTL is set using WithCacheTTL.
+var DefaultCacheTTLs = map[string]time.Duration{
+	"company":          24 * time.Hour,
+	"logo":             24 * time.Hour,
+	"peers":            24 * time.Hour,
+	"ceo-compensation": 24 * time.Hour,
+	"balance-sheet":    12 * time.Hour,
+	"cash-flow":        12 * time.Hour,
+	"income":           12 * time.Hour,
+	"financials":       12 * time.Hour,
+	"symbols":          12 * time.Hour,
+	"sectors":          24 * time.Hour,
+	"tags":             24 * time.Hour,
+	"exchanges":        24 * time.Hour,
+	"dates":            time.Hour,
+}
+
+// WithCache sets the cache used by a new IEX Client. Successful responses are
+// cached with the TTL for their endpoint family. See EndpointFamily and
+// DefaultCacheTTLs.
+func WithCache(cache Cache) ClientOption {
+	return func(client *Client) {
+		client.cache = cache
+	}
+}
+
+// WithCacheTTL overrides the cache TTL for the given endpoint family. A TTL of
+// zero disables caching for the endpoint family.
+func WithCacheTTL(family string, ttl time.Duration) ClientOption {
+	return func(client *Client) {
+		if client.cacheTTLs == nil {
+			client.cacheTTLs = map[string]time.Duration{}
+		}
+		client.cacheTTLs[family] = ttl
+	}
+}
+
+// CacheMode controls how a request uses the client's cache.
+type CacheMode int
+
+// Available cache modes.
+const (
+	// CacheDefault reads from and writes to the cache.
+	CacheDefault CacheMode = iota
+	// CacheBypass neither reads from nor writes to the cache.
+	CacheBypass
+	// CacheRefresh skips reading from the cache but stores the new response.
+	CacheRefresh
+)
+
+type cacheModeKey struct{}
+
+// ContextWithCacheMode returns a copy of the context that makes requests use
+// the cache according to the given mode.
+func ContextWithCacheMode(ctx context.Context, mode CacheMode) context.Context {
+	return context.WithValue(ctx, cacheModeKey{}, mode)
+}
+
+func cacheModeFromContext(ctx context.Context) CacheMode {
+	mode, _ := ctx.Value(cacheModeKey{}).(CacheMode)
+	return mode
+}
+
+// cacheTTL returns the cache TTL for the given endpoint family.
+func (c *Client) cacheTTL(family string) time.Duration {
+	if ttl, ok := c.cacheTTLs[family]; ok {
+		return ttl
+	}
+	return DefaultCacheTTLs[family]
+}
+
+// cacheMiddleware answers GET requests from the client's cache and caches
+// successful responses according to the TTL for their endpoint family. Cache
+// keys are the request URL, which never includes the token, followed by a
+// hash of the token if it isn't the client's own. Account responses are never
+// cached.
+func (c *Client) cacheMiddleware(next Handler) Handler {
+	if c.cache == nil {
+		return next
+	}
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		mode := cacheModeFromContext(ctx)
+		ttl := c.cacheTTL(req.Family())
+		key, ok := c.sharedKey(req)
+		if req.Method != "GET" || ttl <= 0 || mode == CacheBypass || !ok {
+			return next(ctx, req)
+		}
+		if mode != CacheRefresh {
+			if b, ok := c.cache.Get(key); ok {
+				return &Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{},
+					Body:       b,
+					Meta: ResponseMeta{
+						Endpoint:   req.URL.String(),
+						StatusCode: http.StatusOK,
+						Cached:     true,
+					},
+				}, nil
+			}
+		}
+		resp, err := next(ctx, req)
+		if err == nil {
+			c.cache.Set(key, resp.Body, ttl)
+		}
+		return resp, err
+	}
+}
+
+// MemoryCache is an in-memory Cache that evicts the least recently used entry
+// once it holds the maximum number of entries.
+type MemoryCache struct {
+	maxEntries int
+	mu         sync.Mutex
+	ll         *list.List
+	entries    map[string]*list.Element
+}
+
+type memoryCacheEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryCache creates an in-memory LRU cache holding up to maxEntries
+// responses. If maxEntries is zero or less, the number of entries is
+// unbounded.
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		entries:    map[string]*list.Element{},
+	}
+}
+
+// Get implements the Cache interface.
+func (m *MemoryCache) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*memoryCacheEntry)
+	if time.Now().After(e.expires) {
+		m.ll.Remove(el)
+		delete(m.entries, key)
+		return nil, false
+	}
+	m.ll.MoveToFront(el)
+	return e.value, true
+}
+
+// Set implements the Cache interface.
+func (m *MemoryCache) Set(key string, value []byte, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	expires := time.Now().Add(ttl)
+	if el, ok := m.entries[key]; ok {
+		e := el.Value.(*memoryCacheEntry)
+		e.value = value
+		e.expires = expires
+		m.ll.MoveToFront(el)
+		return
+	}
+	m.entries[key] = m.ll.PushFront(&memoryCacheEntry{key: key, value: value, expires: expires})
+	if m.maxEntries > 0 && m.ll.Len() > m.maxEntries {
+		oldest := m.ll.Back()
+		m.ll.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryCacheEntry).key)
+	}
+}
+
+// Len returns the number of entries in the cache, including expired entries
+// that have not yet been evicted.
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ll.Len()
+}
+
+// DiskCache is a Cache that stores each response in its own file within a
+// directory. File names are derived from a hash of the key.
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache creates a cache that stores responses in the given directory,
+// creating the directory if needed.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (d *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:]))
+}
+
+// Get implements the Cache interface.
+func (d *DiskCache) Get(key string) ([]byte, bool) {
+	b, err := os.ReadFile(d.path(key))
+	if err != nil || len(b) < 8 {
+		return nil, false
+	}
+	expires := time.Unix(0, int64(binary.BigEndian.Uint64(b[:8])))
+	if time.Now().After(expires) {
+		os.Remove(d.path(key))
+		return nil, false
+	}
+	return b[8:], true
+}
+
+// Set implements the Cache interface. Errors writing to disk are ignored,
+// since a failed write only means a later cache miss.
+func (d *DiskCache) Set(key string, value []byte, ttl time.Duration) {
+	b := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(b[:8], uint64(time.Now().Add(ttl).UnixNano()))
+	copy(b[8:], value)
+	// Write to a temporary file and rename it so that readers never see a
+	// partially written entry.
+	f, err := os.CreateTemp(d.dir, "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+	if err := os.Rename(f.Name(), d.path(key)); err != nil {
+		os.Remove(f.Name())
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordingCache wraps a MemoryCache and records the keys that are set.
+type recordingCache struct {
+	*MemoryCache
+	keys []string
+}
+
+func (r *recordingCache) Set(key string, value []byte, ttl time.Duration) {
+	r.keys = append(r.keys, key)
+	r.MemoryCache.Set(key, value, ttl)
+}
+
+func TestClientCache(t *testing.T) {
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(`{"symbol": "AAPL"}`))
+	}))
+	defer s.Close()
+	cache := &recordingCache{MemoryCache: NewMemoryCache(10)}
+	client := NewClient(
+		testToken,
+		withBaseAddress(s),
+		WithCache(cache),
+		WithCacheTTL("quote", 0),
+	)
+
+	testCases := []struct {
+		name         string
+		ctx          context.Context
+		call         func(ctx context.Context) error
+		wantRequests int32
+	}{
+		{
+			name:         "company miss",
+			ctx:          context.Background(),
+			call:         func(ctx context.Context) error { _, err := client.Company(ctx, "aapl"); return err },
+			wantRequests: 1,
+		},
+		{
+			name:         "company hit",
+			ctx:          context.Background(),
+			call:         func(ctx context.Context) error { _, err := client.Company(ctx, "aapl"); return err },
+			wantRequests: 0,
+		},
+		{
+			name:         "company bypass",
+			ctx:          ContextWithCacheMode(context.Background(), CacheBypass),
+			call:         func(ctx context.Context) error { _, err := client.Company(ctx, "aapl"); return err },
+			wantRequests: 1,
+		},
+		{
+			name:         "company refresh",
+			ctx:          ContextWithCacheMode(context.Background(), CacheRefresh),
+			call:         func(ctx context.Context) error { _, err := client.Company(ctx, "aapl"); return err },
+			wantRequests: 1,
+		},
+		{
+			name:         "other symbol",
+			ctx:          context.Background(),
+			call:         func(ctx context.Context) error { _, err := client.Company(ctx, "goog"); return err },
+			wantRequests: 1,
+		},
+		{
+			name:         "quote disabled by override",
+			ctx:          context.Background(),
+			call:         func(ctx context.Context) error { _, err := client.Quote(ctx, "aapl"); return err },
+			wantRequests: 1,
+		},
+		{
+			name:         "quote not cached",
+			ctx:          context.Background(),
+			call:         func(ctx context.Context) error { _, err := client.Quote(ctx, "aapl"); return err },
+			wantRequests: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		before := atomic.LoadInt32(&requests)
+		if err := tc.call(tc.ctx); err != nil {
+			t.Fatalf("%s: Error calling endpoint: %s", tc.name, err)
+		}
+		if got := atomic.LoadInt32(&requests) - before; got != tc.wantRequests {
+			t.Errorf("%s: Got %d requests, want %d", tc.name, got, tc.wantRequests)
+		}
+	}
+
+	if got, want := len(cache.keys), 3; got != want {
+		t.Errorf("Got %d cache writes, want %d", got, want)
+	}
+	for _, key := range cache.keys {
+		if strings.Contains(key, "token") || strings.Contains(key, testToken) {
+			t.Errorf("Cache key %q contains the token", key)
+		}
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	m := NewMemoryCache(2)
+	m.Set("a", []byte("1"), time.Hour)
+	m.Set("b", []byte("2"), time.Hour)
+	m.Get("a") // a is now more recently used than b
+	m.Set("c", []byte("3"), time.Hour)
+	m.Set("d", []byte("4"), -time.Second)
+
+	for _, test := range []struct {
+		key    string
+		wantOK bool
+	}{
+		{"a", false}, // evicted by d
+		{"b", false}, // evicted by c
+		{"c", true},
+		{"d", false}, // expired
+	} {
+		if _, ok := m.Get(test.key); ok != test.wantOK {
+			t.Errorf("Get(%q): got ok %t; want %t", test.key, ok, test.wantOK)
+		}
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	d, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("Error creating disk cache: %s", err)
+	}
+	d.Set("http://base/stock/aapl/company", []byte(`{"symbol": "AAPL"}`), time.Hour)
+	d.Set("http://base/stock/goog/company", []byte(`{"symbol": "GOOG"}`), -time.Second)
+
+	got, ok := d.Get("http://base/stock/aapl/company")
+	if !ok || string(got) != `{"symbol": "AAPL"}` {
+		t.Errorf("Got %q, %t; want cached value", got, ok)
+	}
+	if _, ok := d.Get("http://base/stock/goog/company"); ok {
+		t.Errorf("Got expired value, want miss")
+	}
+	if _, ok := d.Get("http://base/stock/msft/company"); ok {
+		t.Errorf("Got value for missing key, want miss")
+	}
+}
@@ -10,7 +10,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
@@ -19,7 +19,6 @@ import (
 	"time"
 
 	"github.com/google/go-querystring/query"
-	sse "github.com/r3labs/sse/v2"
 	"golang.org/x/time/rate"
 )
 
@@ -31,24 +30,26 @@ type Client struct {
 	baseURL     string
 	sseBaseURL  string
 	token       string
+	secretToken string
 	httpClient  *http.Client
 	rateLimiter *rate.Limiter
-}
-
-// Error represents an IEX API error
-type Error struct {
-	StatusCode int
-	Message    string
+	retryPolicy *RetryPolicy
+	budget      *budgetTracker
+	cache       Cache
+	cacheTTLs   map[string]time.Duration
+	coalescer   *coalescer
+	middlewares []Middleware
+	handler     Handler
+	streamHooks []func(context.Context, StreamEvent)
+	logger      *slog.Logger
+	replay      *replayConfig
+	// signingSecret, if set, is used to sign requests.
+	signingSecret string
 }
 
 // ClientOption applies an option to the client.
 type ClientOption func(*Client)
 
-// Error implements the error interface
-func (e Error) Error() string {
-	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
-}
-
 // NewClient creates a client with the given authorization token.
 func NewClient(token string, opts ...ClientOption) *Client {
 	c := &Client{
@@ -59,12 +60,14 @@ func NewClient(token string, opts ...ClientOption) *Client {
 		baseURL:     apiURL,
 		sseBaseURL:  sseURL,
 		rateLimiter: rate.NewLimiter(rate.Every(time.Second), 100),
+		logger:      slog.New(discardHandler{}),
 	}
 
 	// Apply options using the functional option pattern.
 	for _, opt := range opts {
 		opt(c)
 	}
+	c.handler = c.chain()
 
 	return c
 }
@@ -115,7 +118,13 @@ func WithRateLimiter(duration time.Duration, numRequests int) ClientOption {
 
 // GetJSON gets the JSON data from the given endpoint.
 func (c *Client) GetJSON(ctx context.Context, endpoint string, v interface{}) error {
-	u, err := c.url(endpoint, map[string]string{"token": c.token})
+	return c.getJSONWithToken(ctx, endpoint, c.tokenFor(ctx), v)
+}
+
+// getJSONWithToken gets the JSON data from the given endpoint using the given
+// token.
+func (c *Client) getJSONWithToken(ctx context.Context, endpoint, token string, v interface{}) error {
+	u, err := c.url(endpoint, map[string]string{"token": token})
 	if err != nil {
 		return err
 	}
@@ -126,7 +135,7 @@ func (c *Client) GetJSON(ctx context.Context, endpoint string, v interface{}) er
 // query parameters attached.
 func (c *Client) GetJSONWithQueryParams(ctx context.Context,
 	endpoint string, queryParams map[string]string, v interface{}) error {
-	queryParams["token"] = c.token
+	queryParams["token"] = c.tokenFor(ctx)
 	u, err := c.url(endpoint, queryParams)
 	if err != nil {
 		return err
@@ -136,11 +145,16 @@ func (c *Client) GetJSONWithQueryParams(ctx context.Context,
 
 // Fetches JSON content from the given URL and unmarshals it into `v`.
 func (c *Client) FetchURLToJSON(ctx context.Context, u *url.URL, v interface{}) error {
-	data, err := c.getBytes(ctx, u.String())
+	data, err := c.getBytes(ctx, u)
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(data, v)
+	if err := json.Unmarshal(data, v); err != nil {
+		c.logger.LogAttrs(ctx, slog.LevelError, "decoding response",
+			slog.String("url", redactURL(u)), slog.Any("error", err))
+		return err
+	}
+	return nil
 }
 
 // GetJSONWithoutToken gets the JSON data from the given endpoint without
@@ -155,11 +169,11 @@ func (c *Client) GetJSONWithoutToken(ctx context.Context, endpoint string, v int
 
 // GetBytes gets the data from the given endpoint.
 func (c *Client) GetBytes(ctx context.Context, endpoint string) ([]byte, error) {
-	u, err := c.url(endpoint, map[string]string{"token": c.token})
+	u, err := c.url(endpoint, map[string]string{"token": c.tokenFor(ctx)})
 	if err != nil {
 		return nil, err
 	}
-	return c.getBytes(ctx, u.String())
+	return c.getBytes(ctx, u)
 }
 
 // GetFloat64 gets the number from the given endpoint.
@@ -171,33 +185,41 @@ func (c *Client) GetFloat64(ctx context.Context, endpoint string) (float64, erro
 	return strconv.ParseFloat(string(b), 64)
 }
 
-func (c *Client) getBytes(ctx context.Context, address string) ([]byte, error) {
-	req, err := http.NewRequest("GET", address, nil)
+func (c *Client) getBytes(ctx context.Context, u *url.URL) ([]byte, error) {
+	resp, err := c.do(ctx, c.newRequest("GET", u, nil))
 	if err != nil {
 		return []byte{}, err
 	}
-	err = c.rateLimiter.Wait(ctx)
+	return resp.Body, nil
+}
+
+// postJSON posts the given body encoded as JSON to the endpoint. Posts are
+// neither cached, coalesced, nor retried.
+func (c *Client) postJSON(ctx context.Context, endpoint string, body interface{}) ([]byte, error) {
+	u, err := c.url(endpoint, nil)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	data, err := json.Marshal(body)
 	if err != nil {
-		return []byte{}, err
+		return nil, err
 	}
-	defer resp.Body.Close()
-	// Even if GET didn't return an error, check the status code to make sure
-	// everything was ok.
-	if resp.StatusCode != http.StatusOK {
-		b, err := io.ReadAll(resp.Body)
-		msg := ""
-
-		if err == nil {
-			msg = string(b)
-		}
+	req := c.newRequest("POST", u, data)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
 
-		return []byte{}, Error{StatusCode: resp.StatusCode, Message: msg}
+// endpointPath returns the path of the URL relative to the base URL.
+func (c *Client) endpointPath(u *url.URL) string {
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return u.Path
 	}
-	return io.ReadAll(resp.Body)
+	return strings.TrimPrefix(u.Path, strings.TrimSuffix(base.Path, "/"))
 }
 
 // Returns a URL object that points to the endpoint with optional query parameters.
@@ -254,25 +276,47 @@ func (c Client) DataPointNumber(ctx context.Context, symbol, key string) (float6
 
 // AccountMetadata returns information about an IEX Cloud account, such as
 // current tier, payment status, message quote usage, etc. An SK token is
-// required to access.
+// required to access. See WithSecretToken.
 func (c Client) AccountMetadata(ctx context.Context) (AccountMetadata, error) {
-	// FIXME(mdr): Since this requires an SK token, should Client be modified to
-	// have an SK token? Should we change the token get the JSON and then change
-	// it back? Need to think about this.
 	r := AccountMetadata{}
 	endpoint := "/account/metadata"
-	err := c.GetJSON(ctx, endpoint, &r)
+	err := c.getJSONWithToken(ctx, endpoint, c.secretTokenFor(ctx), &r)
 	return r, err
 }
 
-// Usage retrieves the current month usage for your account.
+// Usage retrieves the current month usage for your account. An SK token is
+// required to access. See WithSecretToken.
 func (c Client) Usage(ctx context.Context) (Usage, error) {
 	r := Usage{}
 	endpoint := "/account/usage"
-	err := c.GetJSON(ctx, endpoint, &r)
+	err := c.getJSONWithToken(ctx, endpoint, c.secretTokenFor(ctx), &r)
 	return r, err
 }
 
+// SetMessageBudget sets the total number of messages the account may use in
+// the current billing cycle, after which the account stops responding to
+// requests. An SK token is required to access. See WithSecretToken.
+func (c Client) SetMessageBudget(ctx context.Context, totalMessages int) error {
+	endpoint := "/account/messagebudget"
+	_, err := c.postJSON(ctx, endpoint, struct {
+		Token         string `json:"token"`
+		TotalMessages int    `json:"totalMessages"`
+	}{c.secretTokenFor(ctx), totalMessages})
+	return err
+}
+
+// SetPayAsYouGo enables or disables pay-as-you-go for the account, which
+// allows the account to exceed its message quota. An SK token is required to
+// access. See WithSecretToken.
+func (c Client) SetPayAsYouGo(ctx context.Context, allow bool) error {
+	endpoint := "/account/payasyougo"
+	_, err := c.postJSON(ctx, endpoint, struct {
+		Token string `json:"token"`
+		Allow bool   `json:"allow"`
+	}{c.secretTokenFor(ctx), allow})
+	return err
+}
+
 //////////////////////////////////////////////////////////////////////////////
 //
 // API System Metadata
@@ -300,8 +344,7 @@ func (c Client) AnalystRecommendationsAndTargets(
 	symbol string,
 ) (CoreEstimate, error) {
 	estimate := CoreEstimate{}
-	endpoint := fmt.Sprintf("/time-series/CORE_ESTIMATES/%s", url.PathEscape(symbol))
-	err := c.GetJSON(ctx, endpoint, &estimate)
+	err := c.TimeSeries(ctx, "CORE_ESTIMATES", symbol, "", nil, &estimate)
 	return estimate, err
 }
 
@@ -583,41 +626,48 @@ func (c Client) Quote(ctx context.Context, symbol string) (Quote, error) {
 // QuoteStream streams quote data for the given symbols, until the context is finished.
 // If UTP is true, then you must have an outstanding agreement for UTP otherwise
 // the endpoint will fail with "unauthorized".
+//
+// The stream reconnects automatically when its connection is lost, resuming
+// from the last event received. QuoteStream returns nil once the context is
+// done, or an error if the stream fails. Events that can't be decoded are
+// skipped and reported through OnDecodeError.
 func (c Client) QuoteStream(
 	ctx context.Context,
 	symbols []string,
 	utp bool,
 	callback func(quotes []Quote),
+	opts ...StreamOption,
 ) error {
-	var escapedSymbols []string
-	for _, s := range symbols {
-		escapedSymbols = append(escapedSymbols, url.PathEscape(s))
-	}
-	path := "stocksUSNoUTP"
-	if utp {
-		path = "stocksUS"
-	}
-	endpoint := fmt.Sprintf(
-		"%s/%s?symbols=%s&token=%s",
-		c.sseBaseURL,
-		path,
-		strings.Join(escapedSymbols, ","),
-		c.token,
-	)
-
-	// This blocks until either the context is done or the stream is ended.
-	return sse.NewClient(endpoint).SubscribeWithContext(ctx, "", func(ev *sse.Event) {
+	s := c.newStreamer(quoteChannel(utp), symbols, opts)
+	return s.run(ctx, func(data []byte) (int, error) {
 		var quotes []Quote
-		if err := json.Unmarshal(ev.Data, &quotes); err != nil {
-			fmt.Printf("Error unmarshaling SSE data: %s", err)
-			return
+		if err := json.Unmarshal(data, &quotes); err != nil {
+			return 0, err
 		}
 		if len(quotes) > 0 {
 			callback(quotes)
 		}
+		return len(quotes), nil
 	})
 }
 
+// StreamQuotes is like QuoteStream, but delivers the quotes on the returned
+// Stream's channel instead of calling a function, so that a slow consumer
+// doesn't stall the connection. Use WithBuffer and WithOverflowPolicy to
+// control buffering; OverflowCoalesce keeps the latest quote for each symbol.
+// Use WithInterval to receive quotes at most once per interval.
+func (c Client) StreamQuotes(
+	ctx context.Context,
+	symbols []string,
+	utp bool,
+	opts ...StreamOption,
+) *Stream[Quote] {
+	return newStream(ctx, c.newStreamer(quoteChannel(utp), symbols, opts),
+		decodeJSONArray[Quote],
+		func(q Quote) string { return q.Symbol },
+	)
+}
+
 // BatchQuote returns the quote data for up to 100 stock symbols.
 func (c Client) BatchQuote(ctx context.Context, symbols []string) (map[string]Quote, error) {
 	r := map[string]struct {
@@ -1244,12 +1294,105 @@ func (c Client) CommodityPrice(ctx context.Context, ct CommodityType) (float64,
 	return c.DataPointNumber(ctx, "market", string(ct))
 }
 
+// CommodityHistory returns the prices for the given commodity over the given
+// range, such as LastYear or RelativeRange(5, TimeSeriesYears), in ascending
+// date order.
+func (c Client) CommodityHistory(ctx context.Context, ct CommodityType, r TimeSeriesRange) ([]CommodityPrice, error) {
+	return c.timeSeriesValues(ctx, "ENERGY", string(ct), &TimeSeriesQueryParameters{Range: r})
+}
+
+// CommodityHistoryBetween returns the prices for the given commodity between
+// the from and to dates, inclusive, in ascending date order.
+func (c Client) CommodityHistoryBetween(ctx context.Context, ct CommodityType, from, to time.Time) ([]CommodityPrice, error) {
+	return c.timeSeriesValues(ctx, "ENERGY", string(ct), &TimeSeriesQueryParameters{From: from, To: to})
+}
+
 //////////////////////////////////////////////////////////////////////////////
 //
 // Economic Data Endpoints
 //
 //////////////////////////////////////////////////////////////////////////////
 
+// EconomicIndicator indicates the type of economic indicator.
+type EconomicIndicator string
+
+// Available economic indicators.
+const (
+	ConsumerPriceIndex      EconomicIndicator = "CPIAUCSL"
+	FederalFunds            EconomicIndicator = "FEDFUNDS"
+	RealGDP                 EconomicIndicator = "A191RL1Q225SBEA"
+	InstitutionalMoneyFunds EconomicIndicator = "WIMFSL"
+	InitialClaims           EconomicIndicator = "IC4WSA"
+	IndustrialProduction    EconomicIndicator = "INDPRO"
+	HousingStarts           EconomicIndicator = "HOUST"
+	TotalPayrolls           EconomicIndicator = "PAYEMS"
+	VehicleSales            EconomicIndicator = "TOTALSA"
+	RetailMoneyFunds        EconomicIndicator = "WRMFSL"
+	UnemploymentRate        EconomicIndicator = "UNRATE"
+	RecessionProbability    EconomicIndicator = "RECPROUSM156N"
+)
+
+var economicIndicatorDescriptions = map[EconomicIndicator]string{
+	ConsumerPriceIndex:      "Consumer Price Index for All Urban Consumers",
+	FederalFunds:            "Effective Federal Funds Rate",
+	RealGDP:                 "Real Gross Domestic Product",
+	InstitutionalMoneyFunds: "Institutional Money Funds",
+	InitialClaims:           "Initial Claims (4-week moving average)",
+	IndustrialProduction:    "Industrial Production Index",
+	HousingStarts:           "Total Housing Starts",
+	TotalPayrolls:           "Total Nonfarm Payrolls",
+	VehicleSales:            "Total Vehicle Sales",
+	RetailMoneyFunds:        "Retail Money Funds",
+	UnemploymentRate:        "Unemployment Rate",
+	RecessionProbability:    "US Recession Probabilities",
+}
+
+var economicIndicatorUnits = map[EconomicIndicator]string{
+	ConsumerPriceIndex:      "Index 1982-1984=100",
+	FederalFunds:            "Percent",
+	RealGDP:                 "Percent change from preceding period, annualized",
+	InstitutionalMoneyFunds: "Billions of $USD",
+	InitialClaims:           "Number",
+	IndustrialProduction:    "Index 2017=100",
+	HousingStarts:           "Thousands of units",
+	TotalPayrolls:           "Thousands of persons",
+	VehicleSales:            "Millions of units",
+	RetailMoneyFunds:        "Billions of $USD",
+	UnemploymentRate:        "Percent",
+	RecessionProbability:    "Percent",
+}
+
+// String provides the Stringer interface for EconomicIndicator.
+func (ei EconomicIndicator) String() string {
+	return economicIndicatorDescriptions[ei]
+}
+
+// Units returns the units of the values of the economic indicator.
+func (ei EconomicIndicator) Units() string {
+	return economicIndicatorUnits[ei]
+}
+
+// EconomicData returns the latest value of the given economic indicator.
+func (c Client) EconomicData(ctx context.Context, ei EconomicIndicator) (float64, error) {
+	// By using an explicit type conversion to string we get the indicator
+	// symbol instead of the description, which we would get if we utilized the
+	// Stringer interface.
+	return c.DataPointNumber(ctx, "market", string(ei))
+}
+
+// EconomicHistory returns the observations of the given economic indicator
+// over the given range, such as RelativeRange(10, TimeSeriesYears), in
+// ascending date order.
+func (c Client) EconomicHistory(ctx context.Context, ei EconomicIndicator, r TimeSeriesRange) ([]EconomicObservation, error) {
+	return c.timeSeriesValues(ctx, "ECONOMIC", string(ei), &TimeSeriesQueryParameters{Range: r})
+}
+
+// EconomicHistoryBetween returns the observations of the given economic
+// indicator between the from and to dates, inclusive, in ascending date order.
+func (c Client) EconomicHistoryBetween(ctx context.Context, ei EconomicIndicator, from, to time.Time) ([]EconomicObservation, error) {
+	return c.timeSeriesValues(ctx, "ECONOMIC", string(ei), &TimeSeriesQueryParameters{From: from, To: to})
+}
+
 // CDRateType indicates the type of CD Rate.
 type CDRateType string
 
@@ -1280,7 +1423,7 @@ func (c Client) CDRate(ctx context.Context, cd CDRateType) (float64, error) {
 
 // CPI returns the consumer price index for all urban consumers.
 func (c Client) CPI(ctx context.Context) (float64, error) {
-	return c.DataPointNumber(ctx, "market", "CPIAUCSL")
+	return c.EconomicData(ctx, ConsumerPriceIndex)
 }
 
 // CreditCardInterestRate returns the commercial bank credit card interest
@@ -1291,7 +1434,7 @@ func (c Client) CreditCardInterestRate(ctx context.Context) (float64, error) {
 
 // FederalFundsRate returns the effective federal funds rate.
 func (c Client) FederalFundsRate(ctx context.Context) (float64, error) {
-	return c.DataPointNumber(ctx, "market", "FEDFUNDS")
+	return c.EconomicData(ctx, FederalFunds)
 }
 
 //////////////////////////////////////////////////////////////////////////////
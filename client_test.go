@@ -612,3 +612,114 @@ func TestHistoricalPrices(t *testing.T) {
 		})
 	}
 }
+
+func TestEconomicIndicator(t *testing.T) {
+	for _, ei := range []EconomicIndicator{
+		ConsumerPriceIndex, FederalFunds, RealGDP, InstitutionalMoneyFunds,
+		InitialClaims, IndustrialProduction, HousingStarts, TotalPayrolls,
+		VehicleSales, RetailMoneyFunds, UnemploymentRate, RecessionProbability,
+	} {
+		if ei.String() == "" || ei.Units() == "" {
+			t.Errorf("Got description %q and units %q for %s, want both", ei.String(), ei.Units(), string(ei))
+		}
+	}
+
+	fakeIEX := fakehttpserver.FakeHTTPServer{ResponseJSON: "4.1"}
+	s := httptest.NewServer(http.HandlerFunc(fakeIEX.Handle))
+	defer s.Close()
+	client := NewClient(testToken, withBaseAddress(s))
+
+	got, err := client.EconomicData(context.Background(), UnemploymentRate)
+	if err != nil {
+		t.Fatalf("Error getting economic data: %s", err)
+	}
+	if got != 4.1 {
+		t.Errorf("Got %v, want 4.1", got)
+	}
+	if got, want := fakeIEX.LastURLReceived.Path, "/data-points/market/UNRATE"; got != want {
+		t.Errorf("Got path %s, want %s", got, want)
+	}
+}
+
+func TestTimeSeriesValueHistory(t *testing.T) {
+	fakeIEX := fakehttpserver.FakeHTTPServer{ResponseJSON: `[
+		{
+			"value": 48.52,
+			"id": "ENERGY",
+			"source": "Refinitiv",
+			"key": "DCOILWTICO",
+			"subkey": "NONE",
+			"date": 1609459200000,
+			"updated": 1609545600000
+		}
+	]`}
+	s := httptest.NewServer(http.HandlerFunc(fakeIEX.Handle))
+	defer s.Close()
+	client := NewClient(testToken, withBaseAddress(s))
+
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name      string
+		call      func(ctx context.Context) ([]TimeSeriesValue, error)
+		wantPath  string
+		wantQuery url.Values
+	}{
+		{
+			name: "commodity range",
+			call: func(ctx context.Context) ([]TimeSeriesValue, error) {
+				return client.CommodityHistory(ctx, WestTexasOil, LastYear)
+			},
+			wantPath:  "/time-series/ENERGY/DCOILWTICO",
+			wantQuery: url.Values{"range": {"last-year"}, "sort": {"asc"}, "token": {testToken}},
+		},
+		{
+			name: "commodity dates",
+			call: func(ctx context.Context) ([]TimeSeriesValue, error) {
+				return client.CommodityHistoryBetween(ctx, HenryHubNG, from, to)
+			},
+			wantPath:  "/time-series/ENERGY/DHHNGSP",
+			wantQuery: url.Values{"from": {"2020-01-01"}, "to": {"2021-01-01"}, "sort": {"asc"}, "token": {testToken}},
+		},
+		{
+			name: "economic range",
+			call: func(ctx context.Context) ([]TimeSeriesValue, error) {
+				return client.EconomicHistory(ctx, UnemploymentRate, RelativeRange(10, TimeSeriesYears))
+			},
+			wantPath:  "/time-series/ECONOMIC/UNRATE",
+			wantQuery: url.Values{"range": {"10y"}, "sort": {"asc"}, "token": {testToken}},
+		},
+		{
+			name: "economic dates",
+			call: func(ctx context.Context) ([]TimeSeriesValue, error) {
+				return client.EconomicHistoryBetween(ctx, RealGDP, from, to)
+			},
+			wantPath:  "/time-series/ECONOMIC/A191RL1Q225SBEA",
+			wantQuery: url.Values{"from": {"2020-01-01"}, "to": {"2021-01-01"}, "sort": {"asc"}, "token": {testToken}},
+		},
+	}
+	want := []TimeSeriesValue{{
+		Value:   48.52,
+		ID:      "ENERGY",
+		Source:  "Refinitiv",
+		Key:     "DCOILWTICO",
+		Subkey:  "NONE",
+		Date:    EpochTime(time.Unix(1609459200, 0)),
+		Updated: EpochTime(time.Unix(1609545600, 0)),
+	}}
+	for _, tc := range testCases {
+		got, err := tc.call(context.Background())
+		if err != nil {
+			t.Fatalf("%s: Error getting history: %s", tc.name, err)
+		}
+		if diff := deep.Equal(got, want); diff != nil {
+			t.Errorf("%s: %v", tc.name, diff)
+		}
+		if got := fakeIEX.LastURLReceived.Path; got != tc.wantPath {
+			t.Errorf("%s: Got path %s, want %s", tc.name, got, tc.wantPath)
+		}
+		if diff := deep.Equal(fakeIEX.LastURLReceived.Query(), tc.wantQuery); diff != nil {
+			t.Errorf("%s: query: %v", tc.name, diff)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WithRequestCoalescing makes a new IEX Client share one request among
+// concurrent identical GET requests. Requests are identical when their URLs
+// and tokens match; requests for different accounts are never shared, and
+// neither are account requests. Each caller may still give up on the shared
+// request when its own context is done; the shared request is only cancelled
+// once every caller has given up.
+func WithRequestCoalescing() ClientOption {
+	return func(client *Client) {
+		client.coalescer = &coalescer{calls: map[string]*coalescedCall{}}
+	}
+}
+
+// coalesceMiddleware shares one request among concurrent identical GET
+// requests. Requests are identical when their URLs and tokens match. Account
+// requests are never shared.
+func (c *Client) coalesceMiddleware(next Handler) Handler {
+	if c.coalescer == nil {
+		return next
+	}
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		key, ok := c.sharedKey(req)
+		if req.Method != "GET" || !ok {
+			return next(ctx, req)
+		}
+		return c.coalescer.do(ctx, key, func(ctx context.Context) (*Response, error) {
+			return next(ctx, req)
+		})
+	}
+}
+
+// coalescer deduplicates concurrent calls with the same key.
+type coalescer struct {
+	mu    sync.Mutex
+	calls map[string]*coalescedCall
+}
+
+// coalescedCall is an in-flight call shared by one or more callers.
+type coalescedCall struct {
+	done    chan struct{}
+	resp    *Response
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// do calls fn once for all concurrent callers using the same key. The shared
+// call runs with a context that carries the values of the first caller's
+// context but is only cancelled when every caller has given up.
+func (g *coalescer) do(
+	ctx context.Context,
+	key string,
+	fn func(ctx context.Context) (*Response, error),
+) (*Response, error) {
+	g.mu.Lock()
+	call, ok := g.calls[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(detachedContext{ctx})
+		call = &coalescedCall{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = call
+		go func() {
+			call.resp, call.err = fn(callCtx)
+			g.mu.Lock()
+			// The call may have been forgotten and replaced by a new one
+			// if every caller gave up.
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+			cancel()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		if call.resp == nil {
+			return nil, call.err
+		}
+		// Give each caller its own copy, since callers may modify the
+		// response.
+		resp := *call.resp
+		resp.Body = make([]byte, len(call.resp.Body))
+		copy(resp.Body, call.resp.Body)
+		return &resp, call.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			// Forget the call so that a later caller starts a new request
+			// instead of joining one that is being cancelled.
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// detachedContext carries the values of its parent but is never cancelled and
+// has no deadline.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (d detachedContext) Value(key interface{}) interface{} { return d.parent.Value(key) }
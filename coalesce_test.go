@@ -0,0 +1,147 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRequestCoalescing(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		w.Write([]byte(`{"symbol": "AAPL", "latestPrice": 150}`))
+	}))
+	defer s.Close()
+	client := NewClient(testToken, withBaseAddress(s), WithRequestCoalescing())
+
+	// One caller gives up early, which must not affect the others.
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancelledErr := make(chan error, 1)
+	go func() {
+		_, err := client.Quote(cancelled, "aapl")
+		cancelledErr <- err
+	}()
+
+	const callers = 10
+	var wg sync.WaitGroup
+	quotes := make([]Quote, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			quotes[i], errs[i] = client.Quote(context.Background(), "aapl")
+		}(i)
+	}
+
+	// Wait for every caller to join the shared request before cancelling.
+	for waiters(client.coalescer) < callers+1 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-cancelledErr; err != context.Canceled {
+		t.Errorf("Got error %v for cancelled caller, want %v", err, context.Canceled)
+	}
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("Got %d requests for %d concurrent callers, want 1", got, callers)
+	}
+	for i := 0; i < callers; i++ {
+		if errs[i] != nil {
+			t.Fatalf("Caller %d: Error getting quote: %s", i, errs[i])
+		}
+		if got, want := quotes[i].LatestPrice, 150.0; got != want {
+			t.Errorf("Caller %d: Got %v, want %v", i, got, want)
+		}
+	}
+}
+
+// waiters returns the total number of callers waiting on in-flight calls.
+func waiters(g *coalescer) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	n := 0
+	for _, call := range g.calls {
+		n += call.waiters
+	}
+	return n
+}
+
+func TestCoalescerReplacedCall(t *testing.T) {
+	g := &coalescer{calls: map[string]*coalescedCall{}}
+	var calls int32
+	release := []chan struct{}{make(chan struct{}), make(chan struct{})}
+	fn := func(ctx context.Context) (*Response, error) {
+		n := atomic.AddInt32(&calls, 1)
+		<-release[n-1]
+		return &Response{}, nil
+	}
+
+	// The only caller of the first call gives up, so the call is forgotten
+	// while its request is still in flight.
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := g.do(ctx, "key", fn)
+		firstErr <- err
+	}()
+	for waiters(g) < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	g.mu.Lock()
+	first := g.calls["key"]
+	g.mu.Unlock()
+	cancel()
+	<-firstErr
+
+	// A second call starts under the same key, then the first one ends.
+	secondErr := make(chan error, 2)
+	go func() {
+		_, err := g.do(context.Background(), "key", fn)
+		secondErr <- err
+	}()
+	for waiters(g) < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release[0])
+	<-first.done
+	g.mu.Lock()
+	second := g.calls["key"]
+	g.mu.Unlock()
+	if second == nil || second == first {
+		close(release[1])
+		t.Fatal("Got the second call forgotten when the first ended, want it kept")
+	}
+
+	// A later caller still joins the second call.
+	go func() {
+		_, err := g.do(context.Background(), "key", fn)
+		secondErr <- err
+	}()
+	for waiters(g) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release[1])
+	for i := 0; i < 2; i++ {
+		if err := <-secondErr; err != nil {
+			t.Errorf("Error calling: %s", err)
+		}
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("Got %d calls, want 2", got)
+	}
+}
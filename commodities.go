@@ -7,12 +7,4 @@ package iex
 
 // CommodityPrice models the price for a single commodity when returned using
 // the time series endpoint.
-type CommodityPrice struct {
-	Value   float64   `json:"value"`
-	ID      string    `json:"id"`
-	Source  string    `json:"source"`
-	Key     string    `json:"key"`
-	Subkey  string    `json:"subkey"`
-	Date    EpochTime `json:"date"`
-	Updated EpochTime `json:"updated"`
-}
+type CommodityPrice = TimeSeriesValue
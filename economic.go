@@ -0,0 +1,10 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+// EconomicObservation models a single observation of an economic indicator
+// when returned using the time series endpoint.
+type EconomicObservation = TimeSeriesValue
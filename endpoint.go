@@ -0,0 +1,71 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"strings"
+)
+
+// refDataFamilies are the reference data endpoint families, which are found
+// at varying depths of the /ref-data path.
+var refDataFamilies = []string{
+	"symbols",
+	"sectors",
+	"tags",
+	"exchanges",
+	"dates",
+	"isin",
+	"figi",
+}
+
+// EndpointFamily returns the logical endpoint family for the given endpoint
+// path, which is relative to the base URL. For example, both
+// "/stock/aapl/chart/1m" and "/stock/goog/chart/date/20210801" belong to the
+// "chart" family, while "/ref-data/region/us/symbols" belongs to the "symbols"
+// family. Endpoint families are used to configure cache TTLs and to label
+// metrics and traces without leaking symbols or tokens.
+func EndpointFamily(path string) string {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i := range segments {
+		segments[i] = strings.ToLower(segments[i])
+	}
+	if segments[0] == "" {
+		return "unknown"
+	}
+	switch segments[0] {
+	case "stock":
+		// /stock/{symbol}/{family}/...
+		if len(segments) >= 3 {
+			return segments[2]
+		}
+		return "stock"
+	case "ref-data":
+		for _, s := range segments[1:] {
+			for _, family := range refDataFamilies {
+				if s == family {
+					return family
+				}
+			}
+		}
+		return "ref-data"
+	case "deep":
+		// /deep and /deep/{channel}
+		if len(segments) >= 2 {
+			return "deep-" + segments[1]
+		}
+		return "deep"
+	case "tops":
+		// /tops and /tops/last
+		if len(segments) >= 2 {
+			return segments[1]
+		}
+		return "tops"
+	}
+	return segments[0]
+}
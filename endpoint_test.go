@@ -0,0 +1,41 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import "testing"
+
+func TestEndpointFamily(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"/stock/aapl/quote", "quote"},
+		{"/stock/AAPL/chart/1m", "chart"},
+		{"/stock/aapl/chart/date/20210801?chartByDay=true", "chart"},
+		{"/stock/aapl/balance-sheet", "balance-sheet"},
+		{"/stock/market/batch?symbols=aapl,goog&types=quote", "batch"},
+		{"/stock/market/list/mostactive", "list"},
+		{"/ref-data/symbols", "symbols"},
+		{"/ref-data/region/us/symbols", "symbols"},
+		{"/ref-data/market/us/exchanges", "exchanges"},
+		{"/ref-data/us/dates/trade/next/1/20210801", "dates"},
+		{"/ref-data/sectors", "sectors"},
+		{"/data-points/market/DCOILWTICO", "data-points"},
+		{"/time-series/CORE_ESTIMATES/aapl", "time-series"},
+		{"/deep?symbols=aapl", "deep"},
+		{"/deep/book?symbols=aapl", "deep-book"},
+		{"/tops?symbols=aapl", "tops"},
+		{"/tops/last?symbols=aapl", "last"},
+		{"/account/usage", "account"},
+		{"/status", "status"},
+		{"", "unknown"},
+	}
+	for _, test := range tests {
+		if got := EndpointFamily(test.input); got != test.want {
+			t.Errorf("EndpointFamily(%q): got %q; want %q", test.input, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Sentinel errors used to classify an Error returned by the IEX Cloud API.
+// Use errors.Is to check an error against them.
+var (
+	ErrUnauthorized    = errors.New("iex: unauthorized")
+	ErrPaymentRequired = errors.New("iex: payment required")
+	ErrTierRestricted  = errors.New("iex: forbidden by account tier")
+	ErrNotFound        = errors.New("iex: not found")
+	ErrRateLimited     = errors.New("iex: rate limited")
+)
+
+// redacted replaces the token in URLs that are recorded or logged.
+const redacted = "REDACTED"
+
+// Error represents an IEX API error
+type Error struct {
+	StatusCode int
+	Message    string
+	// Endpoint is the requested URL with the token redacted.
+	Endpoint string
+	// Header contains the response headers.
+	Header http.Header
+	// Duration is how long the request took, excluding any time spent waiting
+	// on the rate limiter.
+	Duration time.Duration
+}
+
+// Error implements the error interface
+func (e Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Is reports whether the error matches the given sentinel error based on the
+// HTTP status code.
+func (e Error) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrPaymentRequired:
+		return e.StatusCode == http.StatusPaymentRequired
+	case ErrTierRestricted:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// redactURL returns the URL as a string with the value of the token query
+// parameter redacted.
+func redactURL(u *url.URL) string {
+	q := u.Query()
+	if _, ok := q["token"]; !ok {
+		return u.String()
+	}
+	q.Set("token", redacted)
+	r := *u
+	r.RawQuery = q.Encode()
+	return r.String()
+}
+
+// redactError redacts the token from the URL of a *url.Error, which is how the
+// http package reports failed requests, and returns the error.
+func redactError(err error) error {
+	var ue *url.Error
+	if !errors.As(err, &ue) {
+		return err
+	}
+	if u, perr := url.Parse(ue.URL); perr == nil {
+		ue.URL = redactURL(u)
+	}
+	return err
+}
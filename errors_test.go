@@ -0,0 +1,75 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/karagog/testutil-go/fakehttpserver"
+)
+
+func TestErrorClassification(t *testing.T) {
+	fakeIEX := fakehttpserver.FakeHTTPServer{}
+	s := httptest.NewServer(http.HandlerFunc(fakeIEX.Handle))
+	defer s.Close()
+	client := NewClient(testToken, withBaseAddress(s))
+
+	sentinels := []error{
+		ErrUnauthorized,
+		ErrPaymentRequired,
+		ErrTierRestricted,
+		ErrNotFound,
+		ErrRateLimited,
+	}
+	testCases := []struct {
+		name               string
+		responseHTTPStatus int
+		want               error
+	}{
+		{"unauthorized", http.StatusUnauthorized, ErrUnauthorized},
+		{"payment required", http.StatusPaymentRequired, ErrPaymentRequired},
+		{"forbidden", http.StatusForbidden, ErrTierRestricted},
+		{"unknown symbol", http.StatusNotFound, ErrNotFound},
+		{"rate limited", http.StatusTooManyRequests, ErrRateLimited},
+		{"server error", http.StatusInternalServerError, nil},
+	}
+
+	for _, tc := range testCases {
+		fakeIEX.ResponseHTTPStatus = tc.responseHTTPStatus
+
+		_, err := client.Quote(context.TODO(), "aapl")
+		if err == nil {
+			t.Fatalf("%s: Got nil error, want error", tc.name)
+		}
+		for _, sentinel := range sentinels {
+			if got, want := errors.Is(err, sentinel), sentinel == tc.want; got != want {
+				t.Errorf("%s: errors.Is(err, %v) = %t, want %t", tc.name, sentinel, got, want)
+			}
+		}
+
+		var e Error
+		if !errors.As(err, &e) {
+			t.Fatalf("%s: errors.As(err, Error) = false, want true", tc.name)
+		}
+		if got, want := e.StatusCode, tc.responseHTTPStatus; got != want {
+			t.Errorf("%s: Got status %d, want %d", tc.name, got, want)
+		}
+		if strings.Contains(e.Endpoint, testToken) {
+			t.Errorf("%s: Endpoint %q contains the token", tc.name, e.Endpoint)
+		}
+		if !strings.Contains(e.Endpoint, "/stock/aapl/quote?token="+redacted) {
+			t.Errorf("%s: Got endpoint %q, want redacted quote endpoint", tc.name, e.Endpoint)
+		}
+		if e.Header.Get("Content-Type") == "" {
+			t.Errorf("%s: Got no response headers", tc.name)
+		}
+	}
+}
@@ -6,7 +6,14 @@ require (
 	github.com/google/go-querystring v1.1.0
 	github.com/karagog/testutil-go v0.0.0-20211208183738-8c37cec26dcb
 	github.com/r3labs/sse/v2 v2.7.5
-	golang.org/x/time v0.0.0-20220722155302-e5dcc9cfc0b9
+	golang.org/x/time v0.3.0
 )
 
-go 1.14
+require (
+	github.com/google/go-cmp v0.7.0 // indirect
+	github.com/stretchr/testify v1.10.0 // indirect
+	golang.org/x/net v0.33.0 // indirect
+	gopkg.in/cenkalti/backoff.v1 v1.1.0 // indirect
+)
+
+go 1.21
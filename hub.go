@@ -0,0 +1,325 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// Hub shares one upstream Subscription between any number of subscribers in
+// the process. Each symbol is subscribed upstream while at least one
+// subscriber wants it, and unsubscribed once the last subscriber that wants it
+// leaves.
+type Hub[T any] struct {
+	up  *Subscription[T]
+	key func(T) string
+
+	// upMu serializes changes to the upstream symbols. The upstream calls are
+	// made without holding mu, since the upstream Unsubscribe waits for a
+	// callback in progress, and the callback takes mu in fanOut.
+	upMu sync.Mutex
+
+	mu    sync.Mutex
+	ended bool
+	refs  map[string]int
+	subs  map[*HubSubscriber[T]]bool
+}
+
+// HubSubscriber receives the items of a Hub for its symbols on a channel.
+// Each subscriber has its own buffer, and a full buffer never holds up the
+// Hub, so a slow subscriber doesn't hold up the others. A subscriber may
+// change its symbols or close from the loop that reads its channel.
+type HubSubscriber[T any] struct {
+	h      *Hub[T]
+	filter func(T) bool
+	ctx    context.Context
+	cancel context.CancelFunc
+	c      chan T
+	buf    *streamBuffer[T]
+	done   chan struct{}
+
+	// symbols is guarded by the Hub's lock.
+	symbols map[string]bool
+}
+
+func newHub[T any](
+	ctx context.Context,
+	c *Client,
+	ch sseChannel,
+	decode func(data []byte) ([]T, error),
+	key func(T) string,
+	opts []StreamOption,
+) *Hub[T] {
+	h := &Hub[T]{
+		key:  key,
+		refs: make(map[string]int),
+		subs: make(map[*HubSubscriber[T]]bool),
+	}
+	h.up = newSubscription(ctx, c, ch, decode, key, h.fanOut, opts)
+	go func() {
+		<-h.up.Done()
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.ended = true
+		for sub := range h.subs {
+			sub.buf.finish()
+		}
+	}()
+	return h
+}
+
+// NewQuoteHub returns a Hub for quotes. The options apply to the upstream
+// connections; see SubscribeQuotes.
+func (c Client) NewQuoteHub(ctx context.Context, utp bool, opts ...StreamOption) *Hub[Quote] {
+	return newHub(ctx, &c, quoteChannel(utp), decodeJSONArray[Quote],
+		func(q Quote) string { return q.Symbol },
+		opts,
+	)
+}
+
+// Subscribe adds a subscriber for the given symbols. If filter isn't nil,
+// only the items for which it returns true are delivered. Use WithBuffer and
+// WithOverflowPolicy to control the subscriber's buffer; other options are
+// ignored. Since the Hub never waits for a subscriber, OverflowBlock, the
+// default, is treated as OverflowDropOldest.
+func (h *Hub[T]) Subscribe(symbols []string, filter func(T) bool, opts ...StreamOption) (*HubSubscriber[T], error) {
+	var cfg streamConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.buffer <= 0 {
+		cfg.buffer = DefaultStreamBuffer
+	}
+	if cfg.overflow == OverflowBlock {
+		cfg.overflow = OverflowDropOldest
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := &HubSubscriber[T]{
+		h:       h,
+		filter:  filter,
+		ctx:     ctx,
+		cancel:  cancel,
+		c:       make(chan T),
+		buf:     newStreamBuffer(cfg.buffer, cfg.overflow, h.key),
+		done:    make(chan struct{}),
+		symbols: make(map[string]bool),
+	}
+	h.upMu.Lock()
+	defer h.upMu.Unlock()
+	h.mu.Lock()
+	if h.ended {
+		h.mu.Unlock()
+		cancel()
+		return nil, ErrSubscriptionClosed
+	}
+	added := h.add(sub, symbols)
+	h.mu.Unlock()
+	if err := h.subscribeUp(added); err != nil {
+		h.mu.Lock()
+		removed := h.remove(sub, symbols)
+		h.mu.Unlock()
+		h.unsubscribeUp(removed)
+		cancel()
+		return nil, err
+	}
+	h.mu.Lock()
+	h.subs[sub] = true
+	h.mu.Unlock()
+	context.AfterFunc(ctx, sub.buf.close)
+	go sub.deliver()
+	return sub, nil
+}
+
+// Symbols returns the symbols subscribed upstream in alphabetical order.
+func (h *Hub[T]) Symbols() []string {
+	return h.up.Symbols()
+}
+
+// Close closes the upstream subscription and ends every subscriber once it
+// has delivered its buffered items. It always returns nil.
+func (h *Hub[T]) Close() error {
+	return h.up.Close()
+}
+
+// Done returns a channel that is closed once the upstream subscription has
+// ended.
+func (h *Hub[T]) Done() <-chan struct{} {
+	return h.up.Done()
+}
+
+// Err reports why the upstream subscription ended. See Subscription.Err.
+func (h *Hub[T]) Err() error {
+	return h.up.Err()
+}
+
+// add subscribes the subscriber to symbols, returning the symbols no other
+// subscriber wants, which must be subscribed upstream. The lock must be held.
+func (h *Hub[T]) add(sub *HubSubscriber[T], symbols []string) []string {
+	var added []string
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(symbol)
+		if sub.symbols[symbol] {
+			continue
+		}
+		sub.symbols[symbol] = true
+		h.refs[symbol]++
+		if h.refs[symbol] == 1 {
+			added = append(added, symbol)
+		}
+	}
+	return added
+}
+
+// remove unsubscribes the subscriber from symbols, returning the symbols no
+// other subscriber wants, which must be unsubscribed upstream. The lock must
+// be held.
+func (h *Hub[T]) remove(sub *HubSubscriber[T], symbols []string) []string {
+	var removed []string
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(symbol)
+		if !sub.symbols[symbol] {
+			continue
+		}
+		delete(sub.symbols, symbol)
+		h.refs[symbol]--
+		if h.refs[symbol] == 0 {
+			delete(h.refs, symbol)
+			removed = append(removed, symbol)
+		}
+	}
+	if h.ended {
+		return nil
+	}
+	return removed
+}
+
+// subscribeUp subscribes upstream to symbols. upMu must be held.
+func (h *Hub[T]) subscribeUp(symbols []string) error {
+	if len(symbols) == 0 {
+		return nil
+	}
+	return h.up.Subscribe(symbols...)
+}
+
+// unsubscribeUp unsubscribes upstream from symbols. upMu must be held.
+func (h *Hub[T]) unsubscribeUp(symbols []string) error {
+	if len(symbols) == 0 {
+		return nil
+	}
+	return h.up.Unsubscribe(symbols...)
+}
+
+// fanOut pushes the items received upstream to the subscribers that want
+// them.
+func (h *Hub[T]) fanOut(items []T) {
+	type delivery struct {
+		sub   *HubSubscriber[T]
+		items []T
+	}
+	var deliveries []delivery
+	h.mu.Lock()
+	for sub := range h.subs {
+		d := delivery{sub: sub}
+		for _, item := range items {
+			if sub.symbols[strings.ToUpper(h.key(item))] {
+				d.items = append(d.items, item)
+			}
+		}
+		if len(d.items) > 0 {
+			deliveries = append(deliveries, d)
+		}
+	}
+	h.mu.Unlock()
+	for _, d := range deliveries {
+		for _, item := range d.items {
+			if d.sub.filter == nil || d.sub.filter(item) {
+				d.sub.buf.push(item)
+			}
+		}
+	}
+}
+
+// C returns the channel on which items are delivered. The channel is closed
+// once the subscriber is closed, or the Hub has ended and all buffered items
+// have been delivered.
+func (s *HubSubscriber[T]) C() <-chan T {
+	return s.c
+}
+
+// Subscribe adds symbols to the subscriber.
+func (s *HubSubscriber[T]) Subscribe(symbols ...string) error {
+	s.h.upMu.Lock()
+	defer s.h.upMu.Unlock()
+	s.h.mu.Lock()
+	if s.h.ended || s.ctx.Err() != nil {
+		s.h.mu.Unlock()
+		return ErrSubscriptionClosed
+	}
+	added := s.h.add(s, symbols)
+	s.h.mu.Unlock()
+	return s.h.subscribeUp(added)
+}
+
+// Unsubscribe removes symbols from the subscriber.
+func (s *HubSubscriber[T]) Unsubscribe(symbols ...string) error {
+	s.h.upMu.Lock()
+	defer s.h.upMu.Unlock()
+	s.h.mu.Lock()
+	if s.h.ended || s.ctx.Err() != nil {
+		s.h.mu.Unlock()
+		return ErrSubscriptionClosed
+	}
+	removed := s.h.remove(s, symbols)
+	s.h.mu.Unlock()
+	return s.h.unsubscribeUp(removed)
+}
+
+// Close removes the subscriber from the Hub and discards any buffered items.
+// It always returns nil.
+func (s *HubSubscriber[T]) Close() error {
+	s.cancel()
+	s.h.upMu.Lock()
+	s.h.mu.Lock()
+	var removed []string
+	if s.h.subs[s] {
+		delete(s.h.subs, s)
+		symbols := make([]string, 0, len(s.symbols))
+		for symbol := range s.symbols {
+			symbols = append(symbols, symbol)
+		}
+		removed = s.h.remove(s, symbols)
+	}
+	s.h.mu.Unlock()
+	s.h.unsubscribeUp(removed)
+	s.h.upMu.Unlock()
+	<-s.done
+	return nil
+}
+
+// Done returns a channel that is closed once the subscriber has ended.
+func (s *HubSubscriber[T]) Done() <-chan struct{} {
+	return s.done
+}
+
+// deliver sends buffered items on the subscriber's channel until the buffer
+// is closed or finished and drained.
+func (s *HubSubscriber[T]) deliver() {
+	defer close(s.done)
+	defer close(s.c)
+	for {
+		item, ok := s.buf.pop()
+		if !ok {
+			return
+		}
+		select {
+		case s.c <- item:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
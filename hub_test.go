@@ -0,0 +1,114 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func receive(t *testing.T, c <-chan Quote, n int) []string {
+	t.Helper()
+	var got []string
+	for len(got) < n {
+		select {
+		case q := <-c:
+			got = append(got, fmt.Sprintf("%s %g", q.Symbol, q.LatestPrice))
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for quotes, got %q", got)
+		}
+	}
+	return got
+}
+
+func TestHub(t *testing.T) {
+	s := newSubscriptionServer(map[string][]string{
+		"MSFT,AAPL": {quoteEvent("AAPL", 1), quoteEvent("MSFT", 1), quoteEvent("MSFT", 2)},
+	})
+	defer s.Close()
+	client := newSSETestClient(s.Server)
+
+	hub := client.NewQuoteHub(context.Background(), false)
+	defer hub.Close()
+	b, err := hub.Subscribe([]string{"msft"}, func(q Quote) bool { return q.LatestPrice >= 2 })
+	if err != nil {
+		t.Fatalf("Error subscribing: %s", err)
+	}
+	s.waitOpen(t, "MSFT")
+	a, err := hub.Subscribe([]string{"aapl", "msft"}, nil)
+	if err != nil {
+		t.Fatalf("Error subscribing: %s", err)
+	}
+	s.waitOpen(t, "MSFT,AAPL")
+
+	if diff := deep.Equal(receive(t, a.C(), 3), []string{"AAPL 1", "MSFT 1", "MSFT 2"}); diff != nil {
+		t.Errorf("a: %v", diff)
+	}
+	if diff := deep.Equal(receive(t, b.C(), 1), []string{"MSFT 2"}); diff != nil {
+		t.Errorf("b: %v", diff)
+	}
+
+	// Symbols are unsubscribed upstream once no subscriber wants them.
+	a.Close()
+	s.waitOpen(t, "MSFT")
+	if diff := deep.Equal(hub.Symbols(), []string{"MSFT"}); diff != nil {
+		t.Error(diff)
+	}
+	if _, ok := <-a.C(); ok {
+		t.Error("Got open channel after Close, want closed")
+	}
+	b.Unsubscribe("msft")
+	s.waitOpen(t)
+
+	hub.Close()
+	if _, ok := <-b.C(); ok {
+		t.Error("Got open channel after the hub closed, want closed")
+	}
+	if _, err := hub.Subscribe([]string{"aapl"}, nil); err != ErrSubscriptionClosed {
+		t.Errorf("Got error %v, want %v", err, ErrSubscriptionClosed)
+	}
+}
+
+func TestHubSubscriberFullBuffer(t *testing.T) {
+	s := newSubscriptionServer(map[string][]string{
+		"MSFT": {quoteEvent("MSFT", 1), quoteEvent("MSFT", 2), quoteEvent("MSFT", 3), quoteEvent("MSFT", 4)},
+	})
+	defer s.Close()
+	client := newSSETestClient(s.Server)
+
+	hub := client.NewQuoteHub(context.Background(), false)
+	defer hub.Close()
+	a, err := hub.Subscribe([]string{"msft"}, nil, WithBuffer(1))
+	if err != nil {
+		t.Fatalf("Error subscribing: %s", err)
+	}
+	b, err := hub.Subscribe([]string{"msft"}, nil)
+	if err != nil {
+		t.Fatalf("Error subscribing: %s", err)
+	}
+	// A full buffer doesn't hold up the other subscribers.
+	receive(t, b.C(), 4)
+
+	// Nothing is reading a's channel, yet it can unsubscribe.
+	unsubscribed := make(chan error, 1)
+	go func() {
+		unsubscribed <- a.Unsubscribe("msft")
+	}()
+	select {
+	case err := <-unsubscribed:
+		if err != nil {
+			t.Errorf("Error unsubscribing: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out unsubscribing with a full buffer")
+	}
+	// The items already buffered are still delivered.
+	receive(t, a.C(), 1)
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithLogger sets the logger for a new IEX Client. Requests are logged at the
+// debug level, or at the warn level if the server returns an error, retries
+// and stream reconnects at the warn level, and failed streams and failures to
+// decode responses at the error level. Tokens are always redacted from logged
+// URLs. By default, the client doesn't log anything.
+func WithLogger(logger *slog.Logger) ClientOption {
+	return func(client *Client) {
+		if logger != nil {
+			client.logger = logger
+		}
+	}
+}
+
+// discardHandler is a slog.Handler that discards all records.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
+
+// logStreamEvent logs a change in the state of an SSE stream. Batches of data
+// are not logged.
+func (c *Client) logStreamEvent(ctx context.Context, ev StreamEvent) {
+	if ev.Batch != 0 {
+		return
+	}
+	level := slog.LevelInfo
+	switch {
+	case ev.State == StreamConnecting:
+		level = slog.LevelDebug
+	case ev.State == StreamReconnecting:
+		level = slog.LevelWarn
+	case ev.State == StreamFailed:
+		level = slog.LevelError
+	}
+	attrs := []slog.Attr{
+		slog.Uint64("stream", ev.ID),
+		slog.String("channel", ev.Channel),
+		slog.Any("symbols", ev.Symbols),
+	}
+	if ev.Err != nil {
+		attrs = append(attrs, slog.Any("error", ev.Err))
+	}
+	c.logger.LogAttrs(ctx, level, "stream "+ev.State.String(), attrs...)
+}
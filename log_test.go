@@ -0,0 +1,113 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stock/aapl/price":
+			if atomic.AddInt32(&requests, 1) == 1 {
+				http.Error(w, "Test-injected error", http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("42.5"))
+		case "/stock/aapl/quote":
+			w.Write([]byte("not json"))
+		case "/stocksUSNoUTP":
+			w.Write([]byte("data: not json\n\n"))
+		}
+	}))
+	defer s.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client := NewClient(testToken,
+		withBaseAddress(s),
+		WithSSEBaseURL("http://"+s.Listener.Addr().String()),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}),
+		WithLogger(logger),
+	)
+	ctx := context.Background()
+	if _, err := client.Price(ctx, "aapl"); err != nil {
+		t.Fatalf("Error getting price: %s", err)
+	}
+	if _, err := client.Quote(ctx, "aapl"); err == nil {
+		t.Fatal("Got nil error decoding quote, want error")
+	}
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	err := client.QuoteStream(streamCtx, []string{"aapl"}, false, func([]Quote) {},
+		OnDecodeError(func(error) { cancel() }))
+	if err != nil {
+		t.Fatalf("Error streaming quotes: %s", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		`level=WARN msg=request method=GET url="http://` + s.Listener.Addr().String() + `/stock/aapl/price?token=REDACTED" status=503`,
+		`level=WARN msg="retrying request"`,
+		`level=DEBUG msg=request method=GET url="http://` + s.Listener.Addr().String() + `/stock/aapl/price?token=REDACTED" status=200`,
+		`level=ERROR msg="decoding response"`,
+		`level=DEBUG msg="stream connecting"`,
+		`level=INFO msg="stream live"`,
+		`level=ERROR msg="decoding stream event"`,
+		`level=INFO msg="stream closed"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Got log without %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, testToken) {
+		t.Errorf("Got token in log:\n%s", out)
+	}
+}
+
+func TestLoggerRedactsTransportErrors(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	base := "http://" + s.Listener.Addr().String()
+	s.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	client := NewClient(testToken, WithBaseURL(base), WithLogger(logger))
+	_, err := client.Price(context.Background(), "aapl")
+	if err == nil {
+		t.Fatal("Got nil error, want error")
+	}
+	if strings.Contains(err.Error(), testToken) {
+		t.Errorf("Got token in error %q", err)
+	}
+	if !strings.Contains(buf.String(), `msg="request failed"`) {
+		t.Errorf("Got log without failed request:\n%s", buf.String())
+	}
+	if strings.Contains(buf.String(), testToken) {
+		t.Errorf("Got token in log:\n%s", buf.String())
+	}
+}
+
+func TestDefaultLoggerDiscards(t *testing.T) {
+	client := NewClient(testToken)
+	if client.logger.Enabled(context.Background(), slog.LevelError) {
+		t.Error("Got default logger enabled, want disabled")
+	}
+	client = NewClient(testToken, WithLogger(nil))
+	if client.logger.Enabled(context.Background(), slog.LevelError) {
+		t.Error("Got logger enabled after WithLogger(nil), want disabled")
+	}
+}
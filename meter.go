@@ -0,0 +1,138 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Response headers used by IEX Cloud for message accounting.
+const (
+	headerMessagesUsed        = "iexcloud-messages-used"
+	headerPremiumMessagesUsed = "iexcloud-premium-messages-used"
+	headerRequestID           = "X-Request-Id"
+)
+
+// ResponseMeta models the metadata for one response from the IEX Cloud API.
+type ResponseMeta struct {
+	// Endpoint is the requested URL with the token redacted.
+	Endpoint            string
+	StatusCode          int
+	MessagesUsed        int
+	PremiumMessagesUsed int
+	Latency             time.Duration
+	RequestID           string
+	// RateLimitWait is the time spent waiting on the rate limiter.
+	RateLimitWait time.Duration
+	// Attempts is the number of attempts made, including retries.
+	Attempts int
+	// Cached indicates the response was served from the client's cache.
+	Cached bool
+}
+
+func newResponseMeta(u *url.URL, resp *http.Response, latency time.Duration) ResponseMeta {
+	return ResponseMeta{
+		Endpoint:            redactURL(u),
+		StatusCode:          resp.StatusCode,
+		MessagesUsed:        headerInt(resp.Header, headerMessagesUsed),
+		PremiumMessagesUsed: headerInt(resp.Header, headerPremiumMessagesUsed),
+		Latency:             latency,
+		RequestID:           resp.Header.Get(headerRequestID),
+		Attempts:            1,
+	}
+}
+
+// headerInt returns the integer value of the given header or zero if the
+// header is missing or malformed.
+func headerInt(h http.Header, key string) int {
+	n, err := strconv.Atoi(h.Get(key))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// Meter collects the ResponseMeta of every response received using a context
+// returned by ContextWithMeter. A Meter is safe for concurrent use.
+type Meter struct {
+	parent    *Meter
+	mu        sync.Mutex
+	responses []ResponseMeta
+}
+
+type meterKey struct{}
+
+// ContextWithMeter returns a copy of the context carrying a new Meter. If the
+// context already carries a Meter, responses recorded by the new Meter are
+// also recorded by the existing one, so the cost of a nested call adds up to
+// the cost of the enclosing call.
+func ContextWithMeter(ctx context.Context) (context.Context, *Meter) {
+	m := &Meter{parent: MeterFromContext(ctx)}
+	return context.WithValue(ctx, meterKey{}, m), m
+}
+
+// MeterFromContext returns the Meter carried by the context or nil if there
+// is none.
+func MeterFromContext(ctx context.Context) *Meter {
+	m, _ := ctx.Value(meterKey{}).(*Meter)
+	return m
+}
+
+// record adds the response metadata to the meter and its ancestors. It is a
+// no-op on a nil Meter.
+func (m *Meter) record(meta ResponseMeta) {
+	for ; m != nil; m = m.parent {
+		m.mu.Lock()
+		m.responses = append(m.responses, meta)
+		m.mu.Unlock()
+	}
+}
+
+// Responses returns the metadata for every response recorded so far, in the
+// order they were received.
+func (m *Meter) Responses() []ResponseMeta {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := make([]ResponseMeta, len(m.responses))
+	copy(r, m.responses)
+	return r
+}
+
+// Requests returns the number of responses recorded so far.
+func (m *Meter) Requests() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.responses)
+}
+
+// MessagesUsed returns the total number of messages used by the recorded
+// responses.
+func (m *Meter) MessagesUsed() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	total := 0
+	for _, r := range m.responses {
+		total += r.MessagesUsed
+	}
+	return total
+}
+
+// PremiumMessagesUsed returns the total number of premium messages used by the
+// recorded responses.
+func (m *Meter) PremiumMessagesUsed() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	total := 0
+	for _, r := range m.responses {
+		total += r.PremiumMessagesUsed
+	}
+	return total
+}
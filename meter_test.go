@@ -0,0 +1,61 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMeter(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("iexcloud-messages-used", "5")
+		w.Header().Set("X-Request-Id", "req-"+r.URL.Path)
+		if r.URL.Path == "/stock/bad/quote" {
+			http.Error(w, "Unknown symbol", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"symbol": "AAPL"}`))
+	}))
+	defer s.Close()
+	client := NewClient(testToken, withBaseAddress(s))
+
+	ctx, outer := ContextWithMeter(context.Background())
+	if _, err := client.Quote(ctx, "aapl"); err != nil {
+		t.Fatalf("Error getting quote: %s", err)
+	}
+	nested, inner := ContextWithMeter(ctx)
+	if _, err := client.Quote(nested, "bad"); err == nil {
+		t.Fatalf("Got nil error, want error")
+	}
+
+	if got, want := outer.Requests(), 2; got != want {
+		t.Errorf("Got %d outer requests, want %d", got, want)
+	}
+	if got, want := outer.MessagesUsed(), 10; got != want {
+		t.Errorf("Got %d outer messages used, want %d", got, want)
+	}
+	if got, want := inner.MessagesUsed(), 5; got != want {
+		t.Errorf("Got %d inner messages used, want %d", got, want)
+	}
+
+	r := inner.Responses()
+	if len(r) != 1 {
+		t.Fatalf("Got %d inner responses, want 1", len(r))
+	}
+	if got, want := r[0].StatusCode, http.StatusNotFound; got != want {
+		t.Errorf("Got status %d, want %d", got, want)
+	}
+	if got, want := r[0].RequestID, "req-/stock/bad/quote"; got != want {
+		t.Errorf("Got request ID %q, want %q", got, want)
+	}
+	if strings.Contains(r[0].Endpoint, testToken) {
+		t.Errorf("Endpoint %q contains the token", r[0].Endpoint)
+	}
+}
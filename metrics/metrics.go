@@ -0,0 +1,135 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+// Package metrics instruments an IEX Cloud client with Prometheus metrics.
+// Metrics are labeled by logical endpoint family, such as "quote" or
+// "balance-sheet", rather than by URL. See iex.EndpointFamily.
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	iex "github.com/goinvest/iexcloud/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "iexcloud"
+
+// Collector collects metrics from the IEX Cloud clients it instruments. A
+// single Collector may instrument several clients.
+type Collector struct {
+	requests        *prometheus.CounterVec
+	duration        *prometheus.HistogramVec
+	messages        *prometheus.CounterVec
+	premiumMessages *prometheus.CounterVec
+	retries         *prometheus.CounterVec
+	cacheHits       *prometheus.CounterVec
+	rateLimitWait   *prometheus.HistogramVec
+	reconnects      *prometheus.CounterVec
+}
+
+// New creates a Collector and registers its metrics with the registerer.
+func New(reg prometheus.Registerer) (*Collector, error) {
+	c := &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Requests sent to the IEX Cloud API by endpoint family and status code.",
+		}, []string{"family", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Duration of requests to the IEX Cloud API, including retries and rate limiting.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"family"}),
+		messages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_used_total",
+			Help:      "Messages used by IEX Cloud API requests.",
+		}, []string{"family"}),
+		premiumMessages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "premium_messages_used_total",
+			Help:      "Premium messages used by IEX Cloud API requests.",
+		}, []string{"family"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "retries_total",
+			Help:      "Retried IEX Cloud API requests.",
+		}, []string{"family"}),
+		cacheHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_hits_total",
+			Help:      "Requests answered from the client's cache.",
+		}, []string{"family"}),
+		rateLimitWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "rate_limit_wait_seconds",
+			Help:      "Time requests spent waiting on the client's rate limiter.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"family"}),
+		reconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "stream_reconnects_total",
+			Help:      "Reconnections of SSE streams by channel.",
+		}, []string{"channel"}),
+	}
+	for _, col := range []prometheus.Collector{
+		c.requests, c.duration, c.messages, c.premiumMessages,
+		c.retries, c.cacheHits, c.rateLimitWait, c.reconnects,
+	} {
+		if err := reg.Register(col); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Option returns a ClientOption that instruments a new IEX Client with the
+// Collector.
+func (c *Collector) Option() iex.ClientOption {
+	return func(client *iex.Client) {
+		iex.WithMiddleware(c.Middleware)(client)
+		iex.WithStreamHook(c.StreamHook)(client)
+	}
+}
+
+// Middleware records metrics for each request. Requests answered from the
+// cache are only counted as cache hits, since they never reach the API.
+// Requests that fail without a response, such as network errors, are counted
+// with the code "error".
+func (c *Collector) Middleware(next iex.Handler) iex.Handler {
+	return func(ctx context.Context, req *iex.Request) (*iex.Response, error) {
+		start := time.Now()
+		resp, err := next(ctx, req)
+		family := req.Family()
+		if resp != nil && resp.Meta.Cached {
+			c.cacheHits.WithLabelValues(family).Inc()
+			return resp, err
+		}
+		code := "error"
+		if resp != nil {
+			code = strconv.Itoa(resp.StatusCode)
+			c.messages.WithLabelValues(family).Add(float64(resp.Meta.MessagesUsed))
+			c.premiumMessages.WithLabelValues(family).Add(float64(resp.Meta.PremiumMessagesUsed))
+			if resp.Meta.Attempts > 1 {
+				c.retries.WithLabelValues(family).Add(float64(resp.Meta.Attempts - 1))
+			}
+			c.rateLimitWait.WithLabelValues(family).Observe(resp.Meta.RateLimitWait.Seconds())
+		}
+		c.requests.WithLabelValues(family, code).Inc()
+		c.duration.WithLabelValues(family).Observe(time.Since(start).Seconds())
+		return resp, err
+	}
+}
+
+// StreamHook records metrics for SSE stream events.
+func (c *Collector) StreamHook(_ context.Context, ev iex.StreamEvent) {
+	if ev.State == iex.StreamReconnecting {
+		c.reconnects.WithLabelValues(ev.Channel).Inc()
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	iex "github.com/goinvest/iexcloud/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const testToken = "not-a-real-token"
+
+func TestCollector(t *testing.T) {
+	var priceRequests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stock/aapl/price":
+			if atomic.AddInt32(&priceRequests, 1) == 1 {
+				http.Error(w, "Test-injected error", http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("iexcloud-messages-used", "1")
+			w.Write([]byte("42.5"))
+		case "/stock/aapl/balance-sheet":
+			w.Header().Set("iexcloud-messages-used", "3000")
+			w.Header().Set("iexcloud-premium-messages-used", "5")
+			w.Write([]byte(`{"symbol": "AAPL"}`))
+		case "/stock/aapl/company":
+			w.Write([]byte(`{"symbol": "AAPL"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	reg := prometheus.NewRegistry()
+	collector, err := New(reg)
+	if err != nil {
+		t.Fatalf("Error creating collector: %s", err)
+	}
+	client := iex.NewClient(testToken,
+		iex.WithBaseURL(s.URL),
+		iex.WithRetryPolicy(iex.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}),
+		iex.WithCache(iex.NewMemoryCache(0)),
+		collector.Option(),
+	)
+	ctx := context.Background()
+	if _, err := client.Price(ctx, "aapl"); err != nil {
+		t.Fatalf("Error getting price: %s", err)
+	}
+	if _, err := client.BalanceSheets(ctx, "aapl", "annual", 1); err != nil {
+		t.Fatalf("Error getting balance sheet: %s", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := client.Company(ctx, "aapl"); err != nil {
+			t.Fatalf("Error getting company: %s", err)
+		}
+	}
+	if _, err := client.Quote(ctx, "aapl"); err == nil {
+		t.Fatal("Got nil error getting quote, want error")
+	}
+
+	for _, tc := range []struct {
+		name   string
+		metric prometheus.Collector
+		labels []string
+		want   float64
+	}{
+		{"price requests", collector.requests, []string{"price", "200"}, 1},
+		{"balance sheet requests", collector.requests, []string{"balance-sheet", "200"}, 1},
+		{"company requests", collector.requests, []string{"company", "200"}, 1},
+		{"quote requests", collector.requests, []string{"quote", "404"}, 1},
+		{"price retries", collector.retries, []string{"price"}, 1},
+		{"company cache hits", collector.cacheHits, []string{"company"}, 1},
+		{"price messages", collector.messages, []string{"price"}, 1},
+		{"balance sheet messages", collector.messages, []string{"balance-sheet"}, 3000},
+		{"balance sheet premium messages", collector.premiumMessages, []string{"balance-sheet"}, 5},
+	} {
+		vec := tc.metric.(*prometheus.CounterVec)
+		if got := testutil.ToFloat64(vec.WithLabelValues(tc.labels...)); got != tc.want {
+			t.Errorf("%s: Got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+	// The cache hit is not observed as a request.
+	if got, want := testutil.CollectAndCount(collector.duration), 4; got != want {
+		t.Errorf("Got %d duration series, want %d", got, want)
+	}
+	if got, want := testutil.CollectAndCount(collector.rateLimitWait), 4; got != want {
+		t.Errorf("Got %d rate limit wait series, want %d", got, want)
+	}
+}
+
+func TestCollectorStreamReconnects(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	collector, err := New(reg)
+	if err != nil {
+		t.Fatalf("Error creating collector: %s", err)
+	}
+	for _, state := range []iex.StreamState{
+		iex.StreamConnecting,
+		iex.StreamLive,
+		iex.StreamReconnecting,
+		iex.StreamLive,
+		iex.StreamReconnecting,
+		iex.StreamLive,
+		iex.StreamClosed,
+	} {
+		collector.StreamHook(context.Background(), iex.StreamEvent{Channel: "stocksUS", State: state})
+	}
+	if got := testutil.ToFloat64(collector.reconnects.WithLabelValues("stocksUS")); got != 2 {
+		t.Errorf("Got %v reconnects, want 2", got)
+	}
+}
+
+func TestScrape(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("42.5"))
+	}))
+	defer s.Close()
+
+	reg := prometheus.NewRegistry()
+	collector, err := New(reg)
+	if err != nil {
+		t.Fatalf("Error creating collector: %s", err)
+	}
+	client := iex.NewClient(testToken, iex.WithBaseURL(s.URL), collector.Option())
+	if _, err := client.Price(context.Background(), "aapl"); err != nil {
+		t.Fatalf("Error getting price: %s", err)
+	}
+
+	scrape := httptest.NewServer(promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	defer scrape.Close()
+	resp, err := http.Get(scrape.URL)
+	if err != nil {
+		t.Fatalf("Error scraping metrics: %s", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Error reading metrics: %s", err)
+	}
+	for _, want := range []string{
+		`iexcloud_requests_total{code="200",family="price"} 1`,
+		`iexcloud_request_duration_seconds_count{family="price"} 1`,
+		`iexcloud_rate_limit_wait_seconds_count{family="price"} 1`,
+		`iexcloud_messages_used_total{family="price"} 0`,
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("Got metrics without %q:\n%s", want, b)
+		}
+	}
+}
+
+func TestNewRejectsDuplicateRegistration(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	if _, err := New(reg); err != nil {
+		t.Fatalf("Error creating collector: %s", err)
+	}
+	if _, err := New(reg); err == nil {
+		t.Error("Got nil error registering twice, want error")
+	}
+}
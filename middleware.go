@@ -0,0 +1,229 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Request models a request to the IEX Cloud API as seen by a Middleware.
+type Request struct {
+	// Method is the HTTP method, either GET or POST.
+	Method string
+	// URL is the request URL without the token.
+	URL *url.URL
+	// Endpoint is the URL path relative to the client's base URL, such as
+	// "/stock/aapl/quote".
+	Endpoint string
+	// Header contains additional request headers.
+	Header http.Header
+	// Body is the request body, which is nil for GET requests.
+	Body []byte
+
+	token string
+}
+
+// Family returns the logical endpoint family of the request. See
+// EndpointFamily.
+func (r *Request) Family() string {
+	return EndpointFamily(r.Endpoint)
+}
+
+// Query returns the query parameters of the request, which never include the
+// token.
+func (r *Request) Query() url.Values {
+	return r.URL.Query()
+}
+
+// Token returns the token used to authorize the request, if any.
+func (r *Request) Token() string {
+	return r.token
+}
+
+// SetToken sets the token used to authorize the request.
+func (r *Request) SetToken(token string) {
+	r.token = token
+}
+
+// Response models a response from the IEX Cloud API as seen by a Middleware.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Meta       ResponseMeta
+}
+
+// Handler handles a request to the IEX Cloud API. If the server responded,
+// the Response is returned even when the error is non-nil, so that
+// middlewares can inspect failed responses.
+type Handler func(ctx context.Context, req *Request) (*Response, error)
+
+// Middleware wraps a Handler to intercept requests and responses.
+type Middleware func(next Handler) Handler
+
+// WithMiddleware adds middlewares to a new IEX Client. Middlewares are
+// applied in the order given, with the first middleware being the outermost.
+// User middlewares wrap the built-in middlewares, so they see each logical
+// call once, including calls answered from the cache, rather than each retry
+// attempt.
+func WithMiddleware(middlewares ...Middleware) ClientOption {
+	return func(client *Client) {
+		client.middlewares = append(client.middlewares, middlewares...)
+	}
+}
+
+// chain builds the client's handler. From the outermost to the innermost, it
+// consists of the user middlewares, caching, request coalescing, retries, the
+// credit budget, rate limiting, and error decoding, all wrapping the HTTP
+// transport.
+func (c *Client) chain() Handler {
+	builtins := []Middleware{
+		c.cacheMiddleware,
+		c.coalesceMiddleware,
+		c.retryMiddleware,
+		c.budgetMiddleware,
+		rateLimitMiddleware(c.rateLimiter),
+		decodeErrorsMiddleware,
+	}
+	h := c.transport
+	for i := len(builtins) - 1; i >= 0; i-- {
+		h = builtins[i](h)
+	}
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		h = c.middlewares[i](h)
+	}
+	return h
+}
+
+// do sends the request through the client's handler.
+func (c *Client) do(ctx context.Context, req *Request) (*Response, error) {
+	return c.handler(ctx, req)
+}
+
+// newRequest creates a Request for the given URL, moving the token from the
+// URL's query parameters into the Request.
+func (c *Client) newRequest(method string, u *url.URL, body []byte) *Request {
+	r := *u
+	q := r.Query()
+	token := q.Get("token")
+	if _, ok := q["token"]; ok {
+		q.Del("token")
+		r.RawQuery = q.Encode()
+	}
+	return &Request{
+		Method:   method,
+		URL:      &r,
+		Endpoint: c.endpointPath(&r),
+		Header:   http.Header{},
+		Body:     body,
+		token:    token,
+	}
+}
+
+// transport sends the request over HTTP. It authorizes the request with the
+// token, either in the URL or by signing the request, and records and logs the
+// response metadata.
+func (c *Client) transport(ctx context.Context, r *Request) (*Response, error) {
+	u := *r.URL
+	if r.token != "" && c.signingSecret == "" {
+		q := u.Query()
+		q.Set("token", r.token)
+		u.RawQuery = q.Encode()
+	}
+	var body io.Reader
+	if r.Body != nil {
+		body = bytes.NewReader(r.Body)
+	}
+	req, err := http.NewRequest(r.Method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range r.Header {
+		req.Header[k] = v
+	}
+	if c.signingSecret != "" {
+		signRequest(req, r.token, c.signingSecret, r.Body, time.Now())
+	}
+	start := time.Now()
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		err = redactError(err)
+		c.logger.LogAttrs(ctx, slog.LevelWarn, "request failed",
+			slog.String("method", r.Method),
+			slog.String("url", redactURL(req.URL)),
+			slog.Any("error", err),
+		)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	meta := newResponseMeta(req.URL, resp, time.Since(start))
+	level := slog.LevelDebug
+	if resp.StatusCode != http.StatusOK {
+		level = slog.LevelWarn
+	}
+	c.logger.LogAttrs(ctx, level, "request",
+		slog.String("method", r.Method),
+		slog.String("url", meta.Endpoint),
+		slog.Int("status", meta.StatusCode),
+		slog.Duration("latency", meta.Latency),
+		slog.Int("messages_used", meta.MessagesUsed),
+		slog.String("request_id", meta.RequestID),
+	)
+	MeterFromContext(ctx).record(meta)
+	c.budget.add(meta.MessagesUsed, time.Now())
+	return &Response{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       b,
+		Meta:       meta,
+	}, err
+}
+
+// decodeErrorsMiddleware turns responses without an OK status code into an
+// Error.
+func decodeErrorsMiddleware(next Handler) Handler {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		resp, err := next(ctx, req)
+		if resp == nil || resp.StatusCode == http.StatusOK {
+			return resp, err
+		}
+		return resp, Error{
+			StatusCode: resp.StatusCode,
+			Message:    string(resp.Body),
+			Endpoint:   resp.Meta.Endpoint,
+			Header:     resp.Header,
+			Duration:   resp.Meta.Latency,
+		}
+	}
+}
+
+// rateLimitMiddleware waits on the rate limiter before each request and
+// records the time spent waiting in the response metadata.
+func rateLimitMiddleware(limiter *rate.Limiter) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			start := time.Now()
+			if err := limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+			wait := time.Since(start)
+			resp, err := next(ctx, req)
+			if resp != nil {
+				resp.Meta.RateLimitWait += wait
+			}
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestMiddlewareSeesRequestAndResponse(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerMessagesUsed, "3")
+		w.Write([]byte("42.5"))
+	}))
+	defer s.Close()
+
+	var gotReq *Request
+	var gotResp *Response
+	record := func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			gotReq = req
+			resp, err := next(ctx, req)
+			gotResp = resp
+			return resp, err
+		}
+	}
+	client := NewClient(testToken, withBaseAddress(s), WithMiddleware(record))
+	if _, err := client.Price(context.Background(), "aapl"); err != nil {
+		t.Fatalf("Error getting price: %s", err)
+	}
+
+	if gotReq == nil || gotResp == nil {
+		t.Fatal("Middleware was not called")
+	}
+	if got, want := gotReq.Endpoint, "/stock/aapl/price"; got != want {
+		t.Errorf("Got endpoint %q, want %q", got, want)
+	}
+	if got, want := gotReq.Family(), "price"; got != want {
+		t.Errorf("Got family %q, want %q", got, want)
+	}
+	if _, ok := gotReq.Query()["token"]; ok {
+		t.Errorf("Got token in query %v, want none", gotReq.Query())
+	}
+	if got := gotReq.Token(); got != testToken {
+		t.Errorf("Got token %q, want %q", got, testToken)
+	}
+	if got, want := gotResp.StatusCode, http.StatusOK; got != want {
+		t.Errorf("Got status %d, want %d", got, want)
+	}
+	if got, want := gotResp.Meta.MessagesUsed, 3; got != want {
+		t.Errorf("Got %d messages used, want %d", got, want)
+	}
+	if got, want := gotResp.Meta.Attempts, 1; got != want {
+		t.Errorf("Got %d attempts, want %d", got, want)
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("42.5"))
+	}))
+	defer s.Close()
+
+	var calls []string
+	named := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, req *Request) (*Response, error) {
+				calls = append(calls, name+" before")
+				resp, err := next(ctx, req)
+				calls = append(calls, name+" after")
+				return resp, err
+			}
+		}
+	}
+	client := NewClient(testToken, withBaseAddress(s),
+		WithMiddleware(named("first"), named("second")),
+		WithMiddleware(named("third")),
+	)
+	if _, err := client.Price(context.Background(), "aapl"); err != nil {
+		t.Fatalf("Error getting price: %s", err)
+	}
+
+	want := []string{
+		"first before", "second before", "third before",
+		"third after", "second after", "first after",
+	}
+	if diff := deep.Equal(calls, want); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte("42.5"))
+	}))
+	defer s.Close()
+
+	errInjected := errors.New("injected fault")
+	fault := func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			if req.Family() == "quote" {
+				return nil, errInjected
+			}
+			return next(ctx, req)
+		}
+	}
+	client := NewClient(testToken, withBaseAddress(s), WithMiddleware(fault))
+
+	if _, err := client.Quote(context.Background(), "aapl"); !errors.Is(err, errInjected) {
+		t.Errorf("Got error %v, want %v", err, errInjected)
+	}
+	if _, err := client.Price(context.Background(), "aapl"); err != nil {
+		t.Errorf("Error getting price: %s", err)
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("Got %d requests, want 1", got)
+	}
+}
+
+func TestMiddlewareSeesErrorResponses(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Test-injected error", http.StatusPaymentRequired)
+	}))
+	defer s.Close()
+
+	var gotStatus int
+	var gotErr error
+	record := func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			resp, err := next(ctx, req)
+			if resp != nil {
+				gotStatus = resp.StatusCode
+			}
+			gotErr = err
+			return resp, err
+		}
+	}
+	client := NewClient(testToken, withBaseAddress(s), WithMiddleware(record))
+	if _, err := client.Price(context.Background(), "aapl"); !errors.Is(err, ErrPaymentRequired) {
+		t.Errorf("Got error %v, want %v", err, ErrPaymentRequired)
+	}
+	if gotStatus != http.StatusPaymentRequired {
+		t.Errorf("Got status %d, want %d", gotStatus, http.StatusPaymentRequired)
+	}
+	if !errors.Is(gotErr, ErrPaymentRequired) {
+		t.Errorf("Middleware got error %v, want %v", gotErr, ErrPaymentRequired)
+	}
+}
+
+func TestMiddlewareSeesCachedResponses(t *testing.T) {
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(`{"symbol":"AAPL"}`))
+	}))
+	defer s.Close()
+
+	var cached []bool
+	record := func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			resp, err := next(ctx, req)
+			if resp != nil {
+				cached = append(cached, resp.Meta.Cached)
+			}
+			return resp, err
+		}
+	}
+	client := NewClient(testToken, withBaseAddress(s),
+		WithCache(NewMemoryCache(0)), WithCacheTTL("company", time.Hour), WithMiddleware(record))
+	for i := 0; i < 2; i++ {
+		if _, err := client.Company(context.Background(), "aapl"); err != nil {
+			t.Fatalf("Error getting company: %s", err)
+		}
+	}
+	if diff := deep.Equal(cached, []bool{false, true}); diff != nil {
+		t.Error(diff)
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("Got %d requests, want 1", got)
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BookSide is a side of an order book.
+type BookSide int
+
+// The sides of an order book.
+const (
+	BidSide BookSide = iota
+	AskSide
+)
+
+// String implements the Stringer interface for BookSide.
+func (s BookSide) String() string {
+	if s == AskSide {
+		return "ask"
+	}
+	return "bid"
+}
+
+// LevelChange is a change of the size at one price level of an order book. A
+// new level has an OldSize of zero and a removed level a NewSize of zero.
+type LevelChange struct {
+	Side    BookSide
+	Price   float64
+	OldSize int
+	NewSize int
+}
+
+// BookChange describes how an update changed an order book.
+type BookChange struct {
+	Symbol  string
+	Seq     int64
+	Changes []LevelChange
+	// BestBid and BestAsk are the top of the book after the update. They are
+	// zero if the side is empty.
+	BestBid BidAsk
+	BestAsk BidAsk
+}
+
+// OrderBook is a price-level order book for one symbol, built from a DEEP
+// book snapshot and the updates of a DEEP book stream. Like the snapshot,
+// each message of the stream is a full book that lists only the levels that
+// exist, so each update replaces the book and a level missing from it has
+// been removed. It is safe for concurrent use.
+type OrderBook struct {
+	symbol string
+
+	mu   sync.RWMutex
+	bids []BidAsk // in descending price order
+	asks []BidAsk // in ascending price order
+	seq  int64
+}
+
+// NewOrderBook returns an OrderBook for the symbol that starts from the
+// snapshot, such as one returned by DEEPBook.
+func NewOrderBook(symbol string, snapshot DEEPBook) *OrderBook {
+	b := &OrderBook{symbol: strings.ToUpper(symbol)}
+	b.Reset(snapshot)
+	return b
+}
+
+// Symbol returns the symbol of the order book.
+func (b *OrderBook) Symbol() string {
+	return b.symbol
+}
+
+// Reset replaces the contents of the order book with the snapshot, such as to
+// resynchronize after the stream reconnects. Levels with a size of zero are
+// ignored. Reset clears the sequence number of the last update applied, since
+// the sequence may start again after a reconnect, so the next update is
+// applied whatever its sequence number.
+func (b *OrderBook) Reset(snapshot DEEPBook) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq = 0
+	b.replace(BidSide, snapshot.Bids)
+	b.replace(AskSide, snapshot.Asks)
+}
+
+// Apply applies an update received from a DEEP book stream, replacing the
+// levels of both sides with those of the update. Levels with a size of zero
+// are ignored. Updates for other symbols, and updates with a sequence number
+// that isn't after the highest one applied, are ignored and Apply returns
+// false.
+func (b *OrderBook) Apply(u DEEPUpdate[DEEPBook]) (BookChange, bool) {
+	if !strings.EqualFold(u.Symbol, b.symbol) {
+		return BookChange{}, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if u.Seq != 0 {
+		if u.Seq <= b.seq {
+			return BookChange{}, false
+		}
+		b.seq = u.Seq
+	}
+	change := BookChange{Symbol: b.symbol, Seq: u.Seq}
+	change.Changes = append(b.replace(BidSide, u.Data.Bids), b.replace(AskSide, u.Data.Asks)...)
+	if len(b.bids) > 0 {
+		change.BestBid = b.bids[0]
+	}
+	if len(b.asks) > 0 {
+		change.BestAsk = b.asks[0]
+	}
+	return change, true
+}
+
+// better reports whether price a is better than price b on the side, which
+// is higher for bids and lower for asks.
+func (s BookSide) better(a, b float64) bool {
+	if s == AskSide {
+		return a < b
+	}
+	return a > b
+}
+
+// replace replaces the levels of one side, returning the levels that changed
+// in book order. The lock must be held.
+func (b *OrderBook) replace(side BookSide, levels []BidAsk) []LevelChange {
+	var next []BidAsk
+	for _, level := range levels {
+		if level.Size <= 0 {
+			continue
+		}
+		i := sort.Search(len(next), func(i int) bool { return !side.better(next[i].Price, level.Price) })
+		if i < len(next) && next[i].Price == level.Price {
+			next[i] = level
+			continue
+		}
+		next = append(next, BidAsk{})
+		copy(next[i+1:], next[i:])
+		next[i] = level
+	}
+	prev := &b.bids
+	if side == AskSide {
+		prev = &b.asks
+	}
+	old := *prev
+	*prev = next
+
+	var changes []LevelChange
+	for i, j := 0, 0; i < len(old) || j < len(next); {
+		switch {
+		case j == len(next) || i < len(old) && side.better(old[i].Price, next[j].Price):
+			changes = append(changes, LevelChange{Side: side, Price: old[i].Price, OldSize: old[i].Size})
+			i++
+		case i == len(old) || side.better(next[j].Price, old[i].Price):
+			changes = append(changes, LevelChange{Side: side, Price: next[j].Price, NewSize: next[j].Size})
+			j++
+		default:
+			if old[i].Size != next[j].Size {
+				changes = append(changes, LevelChange{Side: side, Price: next[j].Price, OldSize: old[i].Size, NewSize: next[j].Size})
+			}
+			i++
+			j++
+		}
+	}
+	return changes
+}
+
+// BestBid returns the highest bid, or false if there are no bids.
+func (b *OrderBook) BestBid() (BidAsk, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.bids) == 0 {
+		return BidAsk{}, false
+	}
+	return b.bids[0], true
+}
+
+// BestAsk returns the lowest ask, or false if there are no asks.
+func (b *OrderBook) BestAsk() (BidAsk, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.asks) == 0 {
+		return BidAsk{}, false
+	}
+	return b.asks[0], true
+}
+
+// Depth returns up to n levels of each side, best first. If n is zero or
+// less, it returns every level.
+func (b *OrderBook) Depth(n int) (bids, asks []BidAsk) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]BidAsk(nil), top(b.bids, n)...), append([]BidAsk(nil), top(b.asks, n)...)
+}
+
+// CumulativeSize returns the total size of up to n levels of one side, best
+// first. If n is zero or less, it returns the size of every level.
+func (b *OrderBook) CumulativeSize(side BookSide, n int) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	levels := b.bids
+	if side == AskSide {
+		levels = b.asks
+	}
+	return totalSize(top(levels, n))
+}
+
+// Midpoint returns the price halfway between the best bid and the best ask,
+// or false if either side is empty.
+func (b *OrderBook) Midpoint() (float64, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.bids) == 0 || len(b.asks) == 0 {
+		return 0, false
+	}
+	return (b.bids[0].Price + b.asks[0].Price) / 2, true
+}
+
+// Spread returns the best ask less the best bid, or false if either side is
+// empty.
+func (b *OrderBook) Spread() (float64, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.bids) == 0 || len(b.asks) == 0 {
+		return 0, false
+	}
+	return b.asks[0].Price - b.bids[0].Price, true
+}
+
+// Imbalance returns (bid size - ask size) / (bid size + ask size) for up to n
+// levels of each side, which ranges from -1 when there are only asks to 1
+// when there are only bids. It returns false if the book is empty. If n is
+// zero or less, every level is included.
+func (b *OrderBook) Imbalance(n int) (float64, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	bid, ask := totalSize(top(b.bids, n)), totalSize(top(b.asks, n))
+	if bid+ask == 0 {
+		return 0, false
+	}
+	return float64(bid-ask) / float64(bid+ask), true
+}
+
+func top(levels []BidAsk, n int) []BidAsk {
+	if n > 0 && n < len(levels) {
+		return levels[:n]
+	}
+	return levels
+}
+
+func totalSize(levels []BidAsk) int {
+	total := 0
+	for _, level := range levels {
+		total += level.Size
+	}
+	return total
+}
+
+// OrderBooks keeps an OrderBook for each of several symbols and routes DEEP
+// book updates to them. It is safe for concurrent use.
+type OrderBooks struct {
+	onChange func(BookChange)
+
+	mu    sync.RWMutex
+	books map[string]*OrderBook
+}
+
+// NewOrderBooks returns OrderBooks that start from the snapshots, such as
+// those returned by DEEPBook. If onChange isn't nil, it is called with the
+// change made by each update that is applied.
+func NewOrderBooks(snapshots map[string]DEEPBook, onChange func(BookChange)) *OrderBooks {
+	o := &OrderBooks{onChange: onChange, books: make(map[string]*OrderBook)}
+	for symbol, snapshot := range snapshots {
+		o.Reset(symbol, snapshot)
+	}
+	return o
+}
+
+// Reset replaces the book of the symbol with the snapshot, adding the symbol
+// if needed.
+func (o *OrderBooks) Reset(symbol string, snapshot DEEPBook) {
+	symbol = strings.ToUpper(symbol)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if b, ok := o.books[symbol]; ok {
+		b.Reset(snapshot)
+		return
+	}
+	o.books[symbol] = NewOrderBook(symbol, snapshot)
+}
+
+// Book returns the book of the symbol, or nil if there isn't one.
+func (o *OrderBooks) Book(symbol string) *OrderBook {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.books[strings.ToUpper(symbol)]
+}
+
+// Apply applies an update to the book of its symbol, starting an empty book
+// if there isn't one. It returns false if the update was ignored. See
+// OrderBook.Apply.
+func (o *OrderBooks) Apply(u DEEPUpdate[DEEPBook]) bool {
+	symbol := strings.ToUpper(u.Symbol)
+	o.mu.Lock()
+	b, ok := o.books[symbol]
+	if !ok {
+		b = NewOrderBook(symbol, DEEPBook{})
+		o.books[symbol] = b
+	}
+	o.mu.Unlock()
+	change, ok := b.Apply(u)
+	if ok && o.onChange != nil {
+		o.onChange(change)
+	}
+	return ok
+}
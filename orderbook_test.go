@@ -0,0 +1,159 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+var testSnapshot = DEEPBook{
+	Bids: []BidAsk{{Price: 99.5, Size: 100}, {Price: 100, Size: 200}, {Price: 99, Size: 300}},
+	Asks: []BidAsk{{Price: 101, Size: 400}, {Price: 100.5, Size: 100}},
+}
+
+func TestOrderBookMetrics(t *testing.T) {
+	b := NewOrderBook("aapl", testSnapshot)
+	bids, asks := b.Depth(2)
+	if diff := deep.Equal(bids, []BidAsk{{Price: 100, Size: 200}, {Price: 99.5, Size: 100}}); diff != nil {
+		t.Errorf("bids: %v", diff)
+	}
+	if diff := deep.Equal(asks, []BidAsk{{Price: 100.5, Size: 100}, {Price: 101, Size: 400}}); diff != nil {
+		t.Errorf("asks: %v", diff)
+	}
+	if bid, _ := b.BestBid(); bid.Price != 100 {
+		t.Errorf("Got best bid %v, want 100", bid.Price)
+	}
+	if ask, _ := b.BestAsk(); ask.Price != 100.5 {
+		t.Errorf("Got best ask %v, want 100.5", ask.Price)
+	}
+	if got := b.CumulativeSize(BidSide, 2); got != 300 {
+		t.Errorf("Got cumulative bid size %d, want 300", got)
+	}
+	if got := b.CumulativeSize(AskSide, 0); got != 500 {
+		t.Errorf("Got cumulative ask size %d, want 500", got)
+	}
+	if got, _ := b.Midpoint(); got != 100.25 {
+		t.Errorf("Got midpoint %v, want 100.25", got)
+	}
+	if got, _ := b.Spread(); got != 0.5 {
+		t.Errorf("Got spread %v, want 0.5", got)
+	}
+	// Top level: 200 bid against 100 ask.
+	if got, _ := b.Imbalance(1); got != 1.0/3 {
+		t.Errorf("Got imbalance %v, want %v", got, 1.0/3)
+	}
+
+	empty := NewOrderBook("aapl", DEEPBook{Bids: testSnapshot.Bids})
+	if _, ok := empty.Midpoint(); ok {
+		t.Error("Got midpoint without asks, want none")
+	}
+	if _, ok := empty.Spread(); ok {
+		t.Error("Got spread without asks, want none")
+	}
+	if got, _ := empty.Imbalance(0); got != 1 {
+		t.Errorf("Got imbalance %v without asks, want 1", got)
+	}
+}
+
+func TestOrderBookApply(t *testing.T) {
+	b := NewOrderBook("AAPL", testSnapshot)
+	testCases := []struct {
+		name    string
+		update  DEEPUpdate[DEEPBook]
+		applied bool
+		want    BookChange
+	}{
+		{
+			// The 100 bid and the 101 ask are missing from the update, so they
+			// are removed.
+			name: "update, add, and remove levels",
+			update: DEEPUpdate[DEEPBook]{Symbol: "AAPL", Seq: 2, Data: DEEPBook{
+				Bids: []BidAsk{{Price: 99.75, Size: 50}, {Price: 99.5, Size: 100}, {Price: 99, Size: 300}},
+				Asks: []BidAsk{{Price: 100.5, Size: 150}, {Price: 102, Size: 0}},
+			}},
+			applied: true,
+			want: BookChange{
+				Symbol: "AAPL",
+				Seq:    2,
+				Changes: []LevelChange{
+					{Side: BidSide, Price: 100, OldSize: 200, NewSize: 0},
+					{Side: BidSide, Price: 99.75, OldSize: 0, NewSize: 50},
+					{Side: AskSide, Price: 100.5, OldSize: 100, NewSize: 150},
+					{Side: AskSide, Price: 101, OldSize: 400, NewSize: 0},
+				},
+				BestBid: BidAsk{Price: 99.75, Size: 50},
+				BestAsk: BidAsk{Price: 100.5, Size: 150},
+			},
+		},
+		{
+			name:   "stale",
+			update: DEEPUpdate[DEEPBook]{Symbol: "AAPL", Seq: 2, Data: DEEPBook{Bids: []BidAsk{{Price: 1, Size: 1}}}},
+		},
+		{
+			name:   "other symbol",
+			update: DEEPUpdate[DEEPBook]{Symbol: "MSFT", Seq: 3, Data: DEEPBook{Bids: []BidAsk{{Price: 1, Size: 1}}}},
+		},
+	}
+	for _, tc := range testCases {
+		got, applied := b.Apply(tc.update)
+		if applied != tc.applied {
+			t.Errorf("%s: Got applied %t, want %t", tc.name, applied, tc.applied)
+		}
+		if diff := deep.Equal(got, tc.want); diff != nil {
+			t.Errorf("%s: %v", tc.name, diff)
+		}
+	}
+	bids, asks := b.Depth(0)
+	want := []BidAsk{{Price: 99.75, Size: 50}, {Price: 99.5, Size: 100}, {Price: 99, Size: 300}}
+	if diff := deep.Equal(bids, want); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(asks, []BidAsk{{Price: 100.5, Size: 150}}); diff != nil {
+		t.Error(diff)
+	}
+
+	// After a reset, updates resume at a lower sequence number, such as
+	// after the stream reconnects.
+	b.Reset(testSnapshot)
+	update := DEEPUpdate[DEEPBook]{Symbol: "AAPL", Seq: 1, Data: DEEPBook{
+		Bids: []BidAsk{{Price: 98, Size: 10}},
+		Asks: []BidAsk{{Price: 103, Size: 20}},
+	}}
+	if _, applied := b.Apply(update); !applied {
+		t.Error("Got update after a reset ignored, want applied")
+	}
+	if bid, _ := b.BestBid(); bid.Price != 98 {
+		t.Errorf("Got best bid %v after reset, want 98", bid.Price)
+	}
+	if _, applied := b.Apply(update); applied {
+		t.Error("Got repeated update applied, want ignored")
+	}
+}
+
+func TestOrderBooks(t *testing.T) {
+	var changes []BookChange
+	o := NewOrderBooks(map[string]DEEPBook{"aapl": testSnapshot}, func(c BookChange) {
+		changes = append(changes, c)
+	})
+	o.Apply(DEEPUpdate[DEEPBook]{Symbol: "AAPL", Seq: 1, Data: DEEPBook{Bids: []BidAsk{{Price: 100, Size: 250}}}})
+	o.Apply(DEEPUpdate[DEEPBook]{Symbol: "MSFT", Seq: 1, Data: DEEPBook{Asks: []BidAsk{{Price: 400, Size: 10}}}})
+	o.Apply(DEEPUpdate[DEEPBook]{Symbol: "MSFT", Seq: 1, Data: DEEPBook{Asks: []BidAsk{{Price: 400, Size: 20}}}})
+
+	if len(changes) != 2 {
+		t.Fatalf("Got %d changes, want 2", len(changes))
+	}
+	if bid, _ := o.Book("aapl").BestBid(); bid.Size != 250 {
+		t.Errorf("Got AAPL best bid size %d, want 250", bid.Size)
+	}
+	if ask, _ := o.Book("MSFT").BestAsk(); ask.Size != 10 {
+		t.Errorf("Got MSFT best ask size %d, want 10", ask.Size)
+	}
+	if o.Book("IBM") != nil {
+		t.Error("Got book for IBM, want nil")
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Recorder appends the raw events received by streams to a writer, such as a
+// file opened with os.O_APPEND, so they can be replayed later using
+// WithReplay. Each event is written with one call to Write as a record of
+// three fields: the receive time in Unix nanoseconds as a uvarint, then the
+// channel and the event data, each as a uvarint length followed by the bytes.
+//
+// A Recorder may be shared by several streams.
+type Recorder struct {
+	mu  sync.Mutex
+	w   io.Writer
+	buf []byte
+	err error
+}
+
+// NewRecorder returns a Recorder that writes to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: w}
+}
+
+// WithRecorder records the raw events received by a stream using r.
+func WithRecorder(r *Recorder) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.recorder = r
+	}
+}
+
+// Err returns the first error writing an event. Recording stops after an
+// error, but the streams being recorded continue.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// record writes an event received at the given time on the given channel.
+func (r *Recorder) record(t time.Time, channel string, data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	b := binary.AppendUvarint(r.buf[:0], uint64(t.UnixNano()))
+	b = binary.AppendUvarint(b, uint64(len(channel)))
+	b = append(b, channel...)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	b = append(b, data...)
+	r.buf = b
+	_, r.err = r.w.Write(b)
+}
+
+// RecordedEvent is an event read from a recording.
+type RecordedEvent struct {
+	Time    time.Time
+	Channel string
+	Data    []byte
+}
+
+// ReadRecordedEvent reads the next event from a recording made by a Recorder.
+// It returns io.EOF at the end of the recording.
+func ReadRecordedEvent(r *bufio.Reader) (RecordedEvent, error) {
+	var ev RecordedEvent
+	ns, err := binary.ReadUvarint(r)
+	if err != nil {
+		return ev, err
+	}
+	channel, err := readRecordField(r)
+	if err != nil {
+		return ev, err
+	}
+	data, err := readRecordField(r)
+	if err != nil {
+		return ev, err
+	}
+	ev.Time = time.Unix(0, int64(ns))
+	ev.Channel = string(channel)
+	ev.Data = data
+	return ev, nil
+}
+
+// readRecordField reads a length-prefixed field of a record.
+func readRecordField(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err == nil && n > maxEventSize {
+		err = fmt.Errorf("record field of %d bytes exceeds %d bytes", n, maxEventSize)
+	}
+	if err != nil {
+		return nil, recordErr(err)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, recordErr(err)
+	}
+	return b, nil
+}
+
+// recordErr reports a recording that ends in the middle of a record.
+func recordErr(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ReplayAsFastAsPossible is the replay speed that replays events without
+// waiting between them.
+const ReplayAsFastAsPossible = 0
+
+// WithReplay makes the client's streams replay the events recorded in the
+// named file instead of connecting to IEX Cloud, so no token is needed. Each
+// stream replays the events recorded for its channel, whatever its symbols,
+// and then ends as if its context were done. A speed of 1 replays the events
+// at the pace they were received, a speed of 10 ten times faster, and
+// ReplayAsFastAsPossible without waiting.
+func WithReplay(name string, speed float64) ClientOption {
+	return func(client *Client) {
+		client.replay = &replayConfig{name: name, speed: speed}
+	}
+}
+
+type replayConfig struct {
+	name  string
+	speed float64
+}
+
+// replay replays the recorded events for the stream's channel, calling handle
+// with the data of each event. It returns nil at the end of the recording or
+// when the context is done.
+func (s *streamer) replay(ctx context.Context, handle func(data []byte) (int, error)) error {
+	s.emit(ctx, StreamConnecting, 0, nil)
+	f, err := os.Open(s.c.replay.name)
+	if err != nil {
+		s.emit(ctx, StreamFailed, 0, err)
+		return err
+	}
+	defer f.Close()
+	s.emit(ctx, StreamLive, 0, nil)
+
+	r := bufio.NewReader(f)
+	start := time.Now()
+	var first time.Time
+	for {
+		ev, err := ReadRecordedEvent(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.emit(ctx, StreamFailed, 0, err)
+			return err
+		}
+		if ev.Channel != s.channel {
+			continue
+		}
+		if first.IsZero() {
+			first = ev.Time
+		}
+		if s.c.replay.speed > 0 {
+			offset := time.Duration(float64(ev.Time.Sub(first)) / s.c.replay.speed)
+			if err := sleepContext(ctx, time.Until(start.Add(offset))); err != nil {
+				break
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		n, err := handle(ev.Data)
+		if err != nil {
+			s.decodeError(ctx, ev.Data, err)
+			continue
+		}
+		if n > 0 {
+			s.emit(ctx, StreamLive, n, nil)
+		}
+	}
+	s.emit(ctx, StreamClosed, 0, nil)
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestRecorder(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(w, "data: %s\n\n", quoteEvent("AAPL", float64(i)))
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer s.Close()
+	client := newSSETestClient(s)
+
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	n := 0
+	before := time.Now()
+	err := client.QuoteStream(ctx, []string{"aapl"}, false,
+		func([]Quote) {
+			if n++; n == 3 {
+				cancel()
+			}
+		},
+		WithRecorder(rec),
+	)
+	if err != nil {
+		t.Fatalf("Error streaming quotes: %s", err)
+	}
+	if err := rec.Err(); err != nil {
+		t.Fatalf("Error recording: %s", err)
+	}
+
+	r := bufio.NewReader(&buf)
+	var data []string
+	for {
+		ev, err := ReadRecordedEvent(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Error reading recording: %s", err)
+		}
+		if ev.Channel != "stocksUSNoUTP" {
+			t.Errorf("Got channel %s, want stocksUSNoUTP", ev.Channel)
+		}
+		if ev.Time.Before(before) || ev.Time.After(time.Now()) {
+			t.Errorf("Got time %v, want the receive time", ev.Time)
+		}
+		data = append(data, string(ev.Data))
+	}
+	want := []string{quoteEvent("AAPL", 1), quoteEvent("AAPL", 2), quoteEvent("AAPL", 3)}
+	if diff := deep.Equal(data, want); diff != nil {
+		t.Error(diff)
+	}
+}
+
+// writeRecording writes a recording of three AAPL quotes spread over 300ms,
+// with a TOPS event in between.
+func writeRecording(t *testing.T) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "recording")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rec := NewRecorder(f)
+	start := time.Date(2024, 3, 1, 14, 30, 0, 0, time.UTC)
+	rec.record(start, "stocksUSNoUTP", []byte(quoteEvent("AAPL", 1)))
+	rec.record(start.Add(100*time.Millisecond), "tops", []byte(`[{"symbol":"AAPL"}]`))
+	rec.record(start.Add(150*time.Millisecond), "stocksUSNoUTP", []byte(quoteEvent("AAPL", 2)))
+	rec.record(start.Add(300*time.Millisecond), "stocksUSNoUTP", []byte(quoteEvent("AAPL", 3)))
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestReplay(t *testing.T) {
+	name := writeRecording(t)
+	testCases := []struct {
+		name    string
+		speed   float64
+		minTime time.Duration
+		maxTime time.Duration
+	}{
+		{"real speed", 1, 300 * time.Millisecond, 5 * time.Second},
+		{"accelerated", 100, 0, 300 * time.Millisecond},
+		{"as fast as possible", ReplayAsFastAsPossible, 0, 300 * time.Millisecond},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := NewClient("", WithReplay(name, tc.speed))
+			start := time.Now()
+			stream := client.StreamQuotes(context.Background(), []string{"aapl"}, false)
+			var prices []float64
+			for q := range stream.C() {
+				prices = append(prices, q.LatestPrice)
+			}
+			elapsed := time.Since(start)
+			if err := stream.Err(); err != nil {
+				t.Errorf("Got error %v, want nil", err)
+			}
+			if diff := deep.Equal(prices, []float64{1, 2, 3}); diff != nil {
+				t.Error(diff)
+			}
+			if elapsed < tc.minTime || elapsed > tc.maxTime {
+				t.Errorf("Replay took %s, want between %s and %s", elapsed, tc.minTime, tc.maxTime)
+			}
+		})
+	}
+}
+
+func TestReplayTruncated(t *testing.T) {
+	name := writeRecording(t)
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, b[:len(b)-1], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient("", WithReplay(name, ReplayAsFastAsPossible))
+	var prices []float64
+	err = client.QuoteStream(context.Background(), []string{"aapl"}, false, func(quotes []Quote) {
+		prices = append(prices, quotes[0].LatestPrice)
+	})
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Got error %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	if diff := deep.Equal(prices, []float64{1, 2}); diff != nil {
+		t.Error(diff)
+	}
+}
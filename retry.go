@@ -0,0 +1,166 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy configures how the Client retries failed requests. Only
+// failures that are safe to repeat are retried: 5xx server errors (other than
+// 501 Not Implemented), 429 Too Many Requests, and connections reset by the
+// server. Every attempt waits on the client's rate limiter.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first. A
+	// value of one or less disables retries.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry. The backoff doubles on
+	// each subsequent retry and is jittered.
+	BaseDelay time.Duration
+	// MaxDelay caps the computed backoff. It does not cap a delay requested by
+	// the server through a Retry-After header.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy returns a RetryPolicy with four attempts, a half second
+// base delay, and a ten second maximum delay.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+	}
+}
+
+// WithRetryPolicy sets the retry policy for a new IEX Client. By default, the
+// client does not retry failed requests.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(client *Client) {
+		client.retryPolicy = &policy
+	}
+}
+
+// retryMiddleware retries failed GET requests according to the client's
+// retry policy. The response metadata records the number of attempts and the
+// total time spent waiting on the rate limiter.
+func (c *Client) retryMiddleware(next Handler) Handler {
+	if c.retryPolicy == nil {
+		return next
+	}
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		var waited time.Duration
+		for attempt := 1; ; attempt++ {
+			resp, err := next(ctx, req)
+			if resp != nil {
+				waited += resp.Meta.RateLimitWait
+			}
+			if err == nil || req.Method != "GET" || !c.retryPolicy.retryable(attempt, err) {
+				if resp != nil {
+					resp.Meta.Attempts = attempt
+					resp.Meta.RateLimitWait = waited
+				}
+				return resp, err
+			}
+			var retryAfter time.Duration
+			if resp != nil {
+				retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			}
+			delay := c.retryPolicy.backoff(attempt, retryAfter)
+			c.logger.LogAttrs(ctx, slog.LevelWarn, "retrying request",
+				slog.String("url", req.URL.String()),
+				slog.Int("attempt", attempt),
+				slog.Duration("delay", delay),
+				slog.Any("error", err),
+			)
+			if err := sleepContext(ctx, delay); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// retryable determines whether the error from the given attempt can be
+// retried under the policy.
+func (p *RetryPolicy) retryable(attempt int, err error) bool {
+	if p == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+	var e Error
+	if errors.As(err, &e) {
+		switch {
+		case e.StatusCode == http.StatusTooManyRequests:
+			return true
+		case e.StatusCode == http.StatusNotImplemented:
+			return false
+		default:
+			return e.StatusCode >= 500
+		}
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// backoff returns how long to wait before the next attempt. A Retry-After
+// value from the server takes precedence when it is longer than the computed
+// backoff.
+func (p *RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	// Use "equal jitter" so that concurrent clients spread out while still
+	// waiting at least half of the computed backoff.
+	if d > 0 {
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+	if retryAfter > d {
+		d = retryAfter
+	}
+	return d
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which may be
+// either a number of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// sleepContext waits for the given duration or until the context is done,
+// whichever happens first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
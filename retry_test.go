@@ -0,0 +1,138 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Millisecond,
+	MaxDelay:    5 * time.Millisecond,
+}
+
+func TestRetry(t *testing.T) {
+	testCases := []struct {
+		name string
+
+		// These configure the fake responses, in order. Once exhausted, the
+		// server responds with 200 OK.
+		responseStatuses []int
+
+		// These set our expectations for the test result.
+		wantAttempts int32
+		wantErr      bool
+	}{
+		{
+			name:         "nominal",
+			wantAttempts: 1,
+		},
+		{
+			name:             "recovers from server errors",
+			responseStatuses: []int{http.StatusBadGateway, http.StatusServiceUnavailable},
+			wantAttempts:     3,
+		},
+		{
+			name:             "recovers from rate limiting",
+			responseStatuses: []int{http.StatusTooManyRequests},
+			wantAttempts:     2,
+		},
+		{
+			name:             "gives up after max attempts",
+			responseStatuses: []int{500, 500, 500, 500},
+			wantAttempts:     3,
+			wantErr:          true,
+		},
+		{
+			name:             "does not retry client errors",
+			responseStatuses: []int{http.StatusNotFound},
+			wantAttempts:     1,
+			wantErr:          true,
+		},
+		{
+			name:             "does not retry not implemented",
+			responseStatuses: []int{http.StatusNotImplemented},
+			wantAttempts:     1,
+			wantErr:          true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var attempts int32
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&attempts, 1)
+				if int(n) <= len(tc.responseStatuses) {
+					http.Error(w, "Test-injected error", tc.responseStatuses[n-1])
+					return
+				}
+				w.Write([]byte("42.5"))
+			}))
+			defer s.Close()
+			client := NewClient(testToken, withBaseAddress(s), WithRetryPolicy(testRetryPolicy))
+
+			got, err := client.Price(context.TODO(), "aapl")
+			if err != nil {
+				if !tc.wantErr {
+					t.Fatalf("%s: Error getting price: %s", tc.name, err)
+				}
+			} else if tc.wantErr {
+				t.Fatalf("%s: Got nil error, want error", tc.name)
+			} else if got != 42.5 {
+				t.Errorf("%s: Got %v, want %v", tc.name, got, 42.5)
+			}
+			if got, want := atomic.LoadInt32(&attempts), tc.wantAttempts; got != want {
+				t.Errorf("%s: Got %d attempts, want %d", tc.name, got, want)
+			}
+		})
+	}
+}
+
+func TestRetryHonorsContext(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "Test-injected error", http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+	client := NewClient(testToken, withBaseAddress(s), WithRetryPolicy(testRetryPolicy))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.Price(ctx, "aapl")
+	if err != context.DeadlineExceeded {
+		t.Errorf("Got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Retry did not stop when the context was done, took %s", elapsed)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2021, 8, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{"-1", 0},
+		{"Sun, 01 Aug 2021 12:00:30 GMT", 30 * time.Second},
+		{"Sun, 01 Aug 2021 11:00:00 GMT", 0},
+		{"foo", 0},
+	}
+	for _, test := range tests {
+		if got := parseRetryAfter(test.input, now); got != test.want {
+			t.Errorf("parseRetryAfter(%q): got %v; want %v", test.input, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Constants used by the IEX Cloud signed request scheme.
+const (
+	signingAlgorithm = "IEX-HMAC-SHA256"
+	signingScope     = "iex_request"
+	signedHeaders    = "host;x-iex-date"
+	headerIEXDate    = "x-iex-date"
+	iexDateFormat    = "20060102T150405Z"
+)
+
+// WithSignedRequests makes a new IEX Client sign every request with the given
+// secret using the IEX Cloud signed request scheme. The token is sent as the
+// credential in the Authorization header instead of in the URL, so that it
+// doesn't end up in proxy logs.
+func WithSignedRequests(secret string) ClientOption {
+	return func(client *Client) {
+		client.signingSecret = secret
+	}
+}
+
+// signRequest adds the signed request headers to the request, using the
+// token as the access key. The body is the request body, which is nil for GET
+// requests.
+func signRequest(req *http.Request, accessKey, secret string, body []byte, now time.Time) {
+	now = now.UTC()
+	iexDate := now.Format(iexDateFormat)
+	datestamp := now.Format("20060102")
+	req.Header.Set(headerIEXDate, iexDate)
+	signature := computeSignature(req.Method, req.URL.Host, req.URL.EscapedPath(),
+		req.URL.RawQuery, iexDate, body, secret)
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s/%s, SignedHeaders=%s, Signature=%s",
+		signingAlgorithm, accessKey, datestamp, signingScope, signedHeaders, signature,
+	))
+}
+
+// computeSignature returns the hex encoded signature of the request.
+func computeSignature(method, host, path, rawQuery, iexDate string, body []byte, secret string) string {
+	datestamp := iexDate[:8]
+	canonicalRequest := strings.Join([]string{
+		method,
+		path,
+		rawQuery,
+		"host:" + host + "\n" + headerIEXDate + ":" + iexDate + "\n",
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+	stringToSign := strings.Join([]string{
+		signingAlgorithm,
+		iexDate,
+		datestamp + "/" + signingScope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+	signingKey := hmacSHA256(hmacSHA256([]byte(secret), datestamp), signingScope)
+	return hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// VerifySignedRequest verifies the signature of a request received by a
+// server, such as a local test server, and returns the access key that signed
+// it. Requests whose x-iex-date differs from the current time by more than
+// maxSkew are rejected; a maxSkew of zero disables the check. The request body
+// is read and replaced so that it can still be read by the caller.
+func VerifySignedRequest(r *http.Request, secret string, maxSkew time.Duration) (string, error) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, signingAlgorithm+" ") {
+		return "", errors.New("missing or unsupported authorization")
+	}
+	fields := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(auth, signingAlgorithm+" "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) == 2 {
+			fields[kv[0]] = kv[1]
+		}
+	}
+	iexDate := r.Header.Get(headerIEXDate)
+	t, err := time.Parse(iexDateFormat, iexDate)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s header: %s", headerIEXDate, err)
+	}
+	if skew := time.Since(t); maxSkew > 0 && (skew > maxSkew || skew < -maxSkew) {
+		return "", fmt.Errorf("request date %s is outside the allowed skew", iexDate)
+	}
+	credential := strings.SplitN(fields["Credential"], "/", 2)
+	if len(credential) != 2 || credential[1] != iexDate[:8]+"/"+signingScope {
+		return "", errors.New("invalid credential")
+	}
+	if fields["SignedHeaders"] != signedHeaders {
+		return "", errors.New("invalid signed headers")
+	}
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			return "", err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	want := computeSignature(r.Method, r.Host, r.URL.EscapedPath(), r.URL.RawQuery, iexDate, body, secret)
+	if !hmac.Equal([]byte(fields["Signature"]), []byte(want)) {
+		return "", errors.New("signature mismatch")
+	}
+	return credential[0], nil
+}
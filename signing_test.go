@@ -0,0 +1,102 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testSigningSecret = "not-a-real-secret"
+
+// signatureVerifier is a fake IEX server that rejects requests that are not
+// correctly signed or that contain the token in the URL.
+type signatureVerifier struct {
+	t            *testing.T
+	accessKey    string
+	responseBody string
+}
+
+func (v *signatureVerifier) Handle(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("token") != "" {
+		v.t.Errorf("%s: Got token in URL", r.URL.Path)
+	}
+	accessKey, err := VerifySignedRequest(r, testSigningSecret, time.Minute)
+	if err != nil {
+		v.t.Errorf("%s: Error verifying signature: %s", r.URL.Path, err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	v.accessKey = accessKey
+	w.Write([]byte(v.responseBody))
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func TestSignedRequests(t *testing.T) {
+	v := &signatureVerifier{t: t, responseBody: `{"symbol": "AAPL"}`}
+	s := httptest.NewServer(http.HandlerFunc(v.Handle))
+	defer s.Close()
+	client := NewClient(testToken, withBaseAddress(s), WithSignedRequests(testSigningSecret))
+
+	if _, err := client.Quote(context.TODO(), "aapl"); err != nil {
+		t.Fatalf("Error getting quote: %s", err)
+	}
+	if v.accessKey != testToken {
+		t.Errorf("Got access key %q, want %q", v.accessKey, testToken)
+	}
+	v.responseBody = `{}`
+	if _, err := client.BatchQuote(context.TODO(), []string{"aapl", "goog"}); err != nil {
+		t.Fatalf("Error getting batch quote: %s", err)
+	}
+	if err := client.SetPayAsYouGo(context.TODO(), false); err != nil {
+		t.Fatalf("Error setting pay as you go: %s", err)
+	}
+
+	v.responseBody = "data: [{\"symbol\": \"AAPL\"}]\n\n"
+	streamClient := NewClient(
+		testToken,
+		WithSSEBaseURL("http://"+s.Listener.Addr().String()),
+		WithSignedRequests(testSigningSecret),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var got []Quote
+	err := streamClient.QuoteStream(ctx, []string{"aapl"}, false, func(quotes []Quote) {
+		got = append(got, quotes...)
+		cancel()
+	})
+	if err != nil {
+		t.Fatalf("Error streaming quotes: %s", err)
+	}
+	if len(got) != 1 || got[0].Symbol != "AAPL" {
+		t.Errorf("Got quotes %v, want one AAPL quote", got)
+	}
+}
+
+func TestVerifySignedRequestRejectsTampering(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://base/stock/aapl/quote", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signRequest(req, testToken, testSigningSecret, nil, time.Now())
+	req.Host = req.URL.Host
+
+	if _, err := VerifySignedRequest(req, testSigningSecret, time.Minute); err != nil {
+		t.Fatalf("Error verifying untampered request: %s", err)
+	}
+	if _, err := VerifySignedRequest(req, "wrong-secret", time.Minute); err == nil {
+		t.Errorf("Got nil error verifying with the wrong secret, want error")
+	}
+	req.URL.Path = "/stock/goog/quote"
+	if _, err := VerifySignedRequest(req, testSigningSecret, time.Minute); err == nil {
+		t.Errorf("Got nil error verifying a tampered path, want error")
+	}
+}
@@ -0,0 +1,326 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/r3labs/sse/v2"
+)
+
+// maxEventSize is the largest SSE event the client accepts.
+const maxEventSize = 1 << 22
+
+// ReconnectPolicy configures how a stream reconnects after its connection is
+// lost.
+type ReconnectPolicy struct {
+	// MaxAttempts is the number of consecutive failed connection attempts
+	// after which the stream fails. Zero means the stream never gives up.
+	MaxAttempts int
+	// BaseDelay is the backoff before the first reconnection attempt. The
+	// backoff doubles on each consecutive failure and is jittered.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff.
+	MaxDelay time.Duration
+}
+
+// DefaultReconnectPolicy returns a ReconnectPolicy that never gives up, with a
+// half second base delay and a thirty second maximum delay.
+func DefaultReconnectPolicy() ReconnectPolicy {
+	return ReconnectPolicy{
+		BaseDelay: 500 * time.Millisecond,
+		MaxDelay:  30 * time.Second,
+	}
+}
+
+// backoff returns how long to wait before the given reconnection attempt.
+func (p ReconnectPolicy) backoff(attempt int) time.Duration {
+	r := RetryPolicy{BaseDelay: p.BaseDelay, MaxDelay: p.MaxDelay}
+	return r.backoff(attempt, 0)
+}
+
+// StreamOption applies an option to an SSE stream.
+type StreamOption func(*streamConfig)
+
+type streamConfig struct {
+	reconnect     ReconnectPolicy
+	onStateChange func(StreamState, error)
+	onDecodeError func(error)
+	buffer        int
+	overflow      OverflowPolicy
+	interval      StreamInterval
+	maxSymbols    int
+	recorder      *Recorder
+}
+
+// WithReconnectPolicy sets the reconnect policy for a stream. By default,
+// streams use DefaultReconnectPolicy.
+func WithReconnectPolicy(policy ReconnectPolicy) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.reconnect = policy
+	}
+}
+
+// OnStateChange sets a function that is called whenever the state of a
+// stream changes, with the error that caused the change, if any. The function
+// is called synchronously and must not block.
+func OnStateChange(fn func(state StreamState, err error)) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.onStateChange = fn
+	}
+}
+
+// OnDecodeError sets a function that is called with each error decoding an
+// event received by a stream. The event is skipped and the stream continues.
+func OnDecodeError(fn func(err error)) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.onDecodeError = fn
+	}
+}
+
+// DecodeError reports an SSE event that could not be decoded.
+type DecodeError struct {
+	Channel string
+	Data    []byte
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	return "iex: decoding " + e.Channel + " event: " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// sseEvent models one event received from an SSE stream.
+type sseEvent struct {
+	ID   string
+	Data []byte
+}
+
+// parseEvent parses a raw SSE event. Multiple data lines are joined with a
+// newline, as required by the SSE specification.
+func parseEvent(raw []byte) sseEvent {
+	var ev sseEvent
+	var data [][]byte
+	for _, line := range bytes.FieldsFunc(raw, func(r rune) bool { return r == '\n' || r == '\r' }) {
+		field, value := line, []byte(nil)
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], bytes.TrimPrefix(line[i+1:], []byte(" "))
+		}
+		switch string(field) {
+		case "id":
+			ev.ID = string(value)
+		case "data":
+			data = append(data, value)
+		}
+	}
+	ev.Data = bytes.Join(data, []byte("\n"))
+	return ev
+}
+
+// streamer maintains the connection of one SSE stream, reconnecting as
+// needed, and reports its state.
+type streamer struct {
+	c       *Client
+	id      uint64
+	channel string
+	path    string
+	params  url.Values
+	symbols []string
+	cfg     streamConfig
+}
+
+func (c *Client) newStreamer(ch sseChannel, symbols []string, opts []StreamOption) *streamer {
+	s := &streamer{
+		c:       c,
+		id:      nextStreamID(),
+		channel: ch.name,
+		path:    ch.path,
+		params:  ch.params,
+		symbols: symbols,
+		cfg:     streamConfig{reconnect: DefaultReconnectPolicy()},
+	}
+	for _, opt := range opts {
+		opt(&s.cfg)
+	}
+	if ch.intervals && s.cfg.interval != IntervalRealtime {
+		s.channel += s.cfg.interval.String()
+		s.path += s.cfg.interval.String()
+	}
+	return s
+}
+
+// emit reports a change in state or a received batch.
+func (s *streamer) emit(ctx context.Context, state StreamState, batch int, err error) {
+	s.c.emitStreamEvent(ctx, StreamEvent{
+		ID:      s.id,
+		Channel: s.channel,
+		Symbols: s.symbols,
+		State:   state,
+		Batch:   batch,
+		Err:     err,
+	})
+	if batch == 0 && s.cfg.onStateChange != nil {
+		s.cfg.onStateChange(state, err)
+	}
+}
+
+// decodeError reports an event that could not be decoded.
+func (s *streamer) decodeError(ctx context.Context, data []byte, err error) {
+	derr := &DecodeError{Channel: s.channel, Data: data, Err: err}
+	s.c.logger.LogAttrs(ctx, slog.LevelError, "decoding stream event",
+		slog.Uint64("stream", s.id),
+		slog.String("channel", s.channel),
+		slog.Any("error", err),
+	)
+	if s.cfg.onDecodeError != nil {
+		s.cfg.onDecodeError(derr)
+	}
+}
+
+// run connects to the stream and calls handle with the data of each event
+// until the context is done or the stream fails. It returns nil when the
+// context is done. Errors returned by handle are reported as decode errors.
+func (s *streamer) run(ctx context.Context, handle func(data []byte) (int, error)) error {
+	if s.c.replay != nil {
+		return s.replay(ctx, handle)
+	}
+	var lastEventID string
+	s.emit(ctx, StreamConnecting, 0, nil)
+	for failures := 0; ; {
+		live, err := s.connect(ctx, &lastEventID, handle)
+		if ctx.Err() != nil {
+			s.emit(ctx, StreamClosed, 0, nil)
+			return nil
+		}
+		if live {
+			failures = 0
+		}
+		failures++
+		err = redactError(err)
+		if !reconnectable(err) ||
+			(s.cfg.reconnect.MaxAttempts > 0 && failures >= s.cfg.reconnect.MaxAttempts) {
+			s.emit(ctx, StreamFailed, 0, err)
+			return err
+		}
+		s.emit(ctx, StreamReconnecting, 0, err)
+		if err := sleepContext(ctx, s.cfg.reconnect.backoff(failures)); err != nil {
+			s.emit(ctx, StreamClosed, 0, nil)
+			return nil
+		}
+	}
+}
+
+// reconnectable determines whether the stream can reconnect after the error.
+// Errors that reconnecting can't fix, such as an invalid token, are not
+// reconnectable.
+func reconnectable(err error) bool {
+	var e Error
+	if errors.As(err, &e) {
+		return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+	}
+	return true
+}
+
+// connect makes one connection to the stream and reads events until the
+// connection is lost. It reports whether the connection went live.
+func (s *streamer) connect(
+	ctx context.Context,
+	lastEventID *string,
+	handle func(data []byte) (int, error),
+) (bool, error) {
+	req, err := s.request(ctx, *lastEventID)
+	if err != nil {
+		return false, err
+	}
+	// Streams are long-lived, so the client's timeout must not apply.
+	hc := *s.c.httpClient
+	hc.Timeout = 0
+	resp, err := hc.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+		return false, Error{
+			StatusCode: resp.StatusCode,
+			Message:    string(b),
+			Endpoint:   redactURL(req.URL),
+			Header:     resp.Header,
+		}
+	}
+	s.emit(ctx, StreamLive, 0, nil)
+
+	reader := sse.NewEventStreamReader(resp.Body, maxEventSize)
+	for {
+		raw, err := reader.ReadEvent()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return true, err
+		}
+		ev := parseEvent(raw)
+		if ev.ID != "" {
+			*lastEventID = ev.ID
+		}
+		if len(ev.Data) == 0 {
+			continue
+		}
+		if s.cfg.recorder != nil {
+			s.cfg.recorder.record(time.Now(), s.channel, ev.Data)
+		}
+		n, err := handle(ev.Data)
+		if err != nil {
+			s.decodeError(ctx, ev.Data, err)
+			continue
+		}
+		if n > 0 {
+			s.emit(ctx, StreamLive, n, nil)
+		}
+	}
+}
+
+// request creates the HTTP request for the stream, authorized with the token
+// either in the URL or by signing the request.
+func (s *streamer) request(ctx context.Context, lastEventID string) (*http.Request, error) {
+	var escaped []string
+	for _, symbol := range s.symbols {
+		escaped = append(escaped, url.PathEscape(symbol))
+	}
+	endpoint := s.c.sseBaseURL + "/" + s.path + "?symbols=" + strings.Join(escaped, ",")
+	if len(s.params) > 0 {
+		endpoint += "&" + s.params.Encode()
+	}
+	token := s.c.tokenFor(ctx)
+	if s.c.signingSecret == "" {
+		endpoint += "&token=" + url.QueryEscape(token)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	if s.c.signingSecret != "" {
+		signRequest(req, token, s.c.signingSecret, nil, time.Now())
+	}
+	return req, nil
+}
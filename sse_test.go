@@ -0,0 +1,229 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+var testReconnectPolicy = ReconnectPolicy{BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+func newSSETestClient(s *httptest.Server, opts ...ClientOption) *Client {
+	opts = append([]ClientOption{WithSSEBaseURL("http://" + s.Listener.Addr().String())}, opts...)
+	return NewClient(testToken, opts...)
+}
+
+func TestParseEvent(t *testing.T) {
+	for _, tc := range []struct {
+		raw  string
+		want sseEvent
+	}{
+		{"data: [1]", sseEvent{Data: []byte("[1]")}},
+		{"id: 42\ndata: [1]", sseEvent{ID: "42", Data: []byte("[1]")}},
+		{"id:42\r\ndata:[1,\r\ndata:2]", sseEvent{ID: "42", Data: []byte("[1,\n2]")}},
+		{": heartbeat", sseEvent{Data: []byte{}}},
+		{"event: message\ndata: {}", sseEvent{Data: []byte("{}")}},
+	} {
+		if diff := deep.Equal(parseEvent([]byte(tc.raw)), tc.want); diff != nil {
+			t.Errorf("%q: %v", tc.raw, diff)
+		}
+	}
+}
+
+func TestStreamReconnects(t *testing.T) {
+	var mu sync.Mutex
+	var lastEventIDs []string
+	connections := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		connections++
+		n := connections
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		mu.Unlock()
+		switch n {
+		case 1:
+			// Drop the connection after one event.
+			fmt.Fprintf(w, "id: 1\ndata: [{\"symbol\": \"AAPL\", \"latestPrice\": 1}]\n\n")
+		case 2:
+			http.Error(w, "Test-injected error", http.StatusBadGateway)
+		default:
+			fmt.Fprintf(w, "id: 2\ndata: [{\"symbol\": \"AAPL\", \"latestPrice\": 2}]\n\n")
+		}
+	}))
+	defer s.Close()
+	client := newSSETestClient(s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var prices []float64
+	var states []StreamState
+	err := client.QuoteStream(ctx, []string{"aapl"}, false,
+		func(quotes []Quote) {
+			prices = append(prices, quotes[0].LatestPrice)
+			if len(prices) == 2 {
+				cancel()
+			}
+		},
+		WithReconnectPolicy(testReconnectPolicy),
+		OnStateChange(func(state StreamState, err error) {
+			states = append(states, state)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Error streaming quotes: %s", err)
+	}
+	if diff := deep.Equal(prices, []float64{1, 2}); diff != nil {
+		t.Errorf("prices: %v", diff)
+	}
+	wantStates := []StreamState{
+		StreamConnecting, StreamLive,
+		StreamReconnecting,
+		StreamReconnecting, StreamLive,
+		StreamClosed,
+	}
+	if diff := deep.Equal(states, wantStates); diff != nil {
+		t.Errorf("states: %v", diff)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if diff := deep.Equal(lastEventIDs, []string{"", "1", "1"}); diff != nil {
+		t.Errorf("Last-Event-ID: %v", diff)
+	}
+}
+
+func TestStreamFails(t *testing.T) {
+	testCases := []struct {
+		name       string
+		status     int
+		policy     ReconnectPolicy
+		wantStates []StreamState
+		wantErr    error
+	}{
+		{
+			name:       "unauthorized",
+			status:     http.StatusUnauthorized,
+			policy:     testReconnectPolicy,
+			wantStates: []StreamState{StreamConnecting, StreamFailed},
+			wantErr:    ErrUnauthorized,
+		},
+		{
+			name:   "too many attempts",
+			status: http.StatusServiceUnavailable,
+			policy: ReconnectPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+			wantStates: []StreamState{
+				StreamConnecting, StreamReconnecting, StreamReconnecting, StreamFailed,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "Test-injected error", tc.status)
+			}))
+			defer s.Close()
+			client := newSSETestClient(s)
+
+			var states []StreamState
+			var stateErr error
+			err := client.QuoteStream(context.Background(), []string{"aapl"}, false,
+				func([]Quote) {},
+				WithReconnectPolicy(tc.policy),
+				OnStateChange(func(state StreamState, err error) {
+					states = append(states, state)
+					stateErr = err
+				}),
+			)
+			if err == nil {
+				t.Fatal("Got nil error, want error")
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Errorf("Got error %v, want %v", err, tc.wantErr)
+			}
+			if stateErr == nil || stateErr.Error() != err.Error() {
+				t.Errorf("Got failed state error %v, want %v", stateErr, err)
+			}
+			if diff := deep.Equal(states, tc.wantStates); diff != nil {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestStreamDecodeErrors(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "data: not json\n\n")
+		fmt.Fprintf(w, "data: [{\"symbol\": \"AAPL\"}]\n\n")
+	}))
+	defer s.Close()
+	client := newSSETestClient(s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var decodeErrs []error
+	var got []Quote
+	err := client.QuoteStream(ctx, []string{"aapl"}, false,
+		func(quotes []Quote) {
+			got = append(got, quotes...)
+			cancel()
+		},
+		OnDecodeError(func(err error) {
+			decodeErrs = append(decodeErrs, err)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Error streaming quotes: %s", err)
+	}
+	if len(got) != 1 || got[0].Symbol != "AAPL" {
+		t.Errorf("Got quotes %v, want one AAPL quote", got)
+	}
+	if len(decodeErrs) != 1 {
+		t.Fatalf("Got %d decode errors, want 1", len(decodeErrs))
+	}
+	var derr *DecodeError
+	if !errors.As(decodeErrs[0], &derr) {
+		t.Fatalf("Got error %T, want *DecodeError", decodeErrs[0])
+	}
+	if derr.Channel != "stocksUSNoUTP" || string(derr.Data) != "not json" {
+		t.Errorf("Got decode error for %s %q, want stocksUSNoUTP \"not json\"", derr.Channel, derr.Data)
+	}
+}
+
+func TestStreamIgnoresClientTimeout(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		time.Sleep(100 * time.Millisecond)
+		fmt.Fprintf(w, "data: [{\"symbol\": \"AAPL\"}]\n\n")
+	}))
+	defer s.Close()
+	client := newSSETestClient(s, WithHTTPClient(&http.Client{Timeout: 10 * time.Millisecond}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var reconnects int
+	err := client.QuoteStream(ctx, []string{"aapl"}, false,
+		func([]Quote) { cancel() },
+		OnStateChange(func(state StreamState, err error) {
+			if state == StreamReconnecting {
+				reconnects++
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Error streaming quotes: %s", err)
+	}
+	if reconnects != 0 {
+		t.Errorf("Got %d reconnects, want 0", reconnects)
+	}
+}
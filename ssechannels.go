@@ -0,0 +1,232 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+)
+
+// sseChannel identifies an IEX Cloud SSE channel.
+type sseChannel struct {
+	// name is the channel name reported in StreamEvents, such as "stocksUS"
+	// or "deep-trades".
+	name string
+	// path is the URL path of the channel relative to the SSE base URL.
+	path string
+	// params are additional query parameters, such as the DEEP channels.
+	params url.Values
+	// intervals indicates the channel has 1 second, 5 second, and 1 minute
+	// variants. See WithInterval.
+	intervals bool
+}
+
+func quoteChannel(utp bool) sseChannel {
+	if utp {
+		return sseChannel{name: "stocksUS", path: "stocksUS", intervals: true}
+	}
+	return sseChannel{name: "stocksUSNoUTP", path: "stocksUSNoUTP", intervals: true}
+}
+
+func deepChannel(channel string) sseChannel {
+	name := "deep"
+	if channel != "deep" {
+		name = "deep-" + channel
+	}
+	return sseChannel{name: name, path: "deep", params: url.Values{"channels": {channel}}}
+}
+
+// StreamInterval is how often a stream delivers updates. Only quote and forex
+// streams support intervals other than IntervalRealtime.
+type StreamInterval int
+
+// Available stream intervals.
+const (
+	IntervalRealtime StreamInterval = iota
+	Interval1Second
+	Interval5Second
+	Interval1Minute
+)
+
+// String returns the suffix IEX Cloud uses for the interval's channels.
+func (i StreamInterval) String() string {
+	switch i {
+	case Interval1Second:
+		return "1Second"
+	case Interval5Second:
+		return "5Second"
+	case Interval1Minute:
+		return "1Minute"
+	}
+	return ""
+}
+
+// WithInterval makes a quote or forex stream deliver updates at most once per
+// interval instead of in real time. It has no effect on other streams.
+func WithInterval(interval StreamInterval) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.interval = interval
+	}
+}
+
+// DEEPUpdate is a message received from a DEEP stream. Data holds the update
+// for the symbol, such as a Trade for a DEEP trades stream.
+type DEEPUpdate[T any] struct {
+	Symbol string
+	Seq    int64
+	Data   T
+}
+
+// deepMessage models the envelope of a message received from a DEEP stream.
+type deepMessage struct {
+	Symbol      string          `json:"symbol"`
+	MessageType string          `json:"messageType"`
+	Data        json.RawMessage `json:"data"`
+	Seq         int64           `json:"seq"`
+}
+
+// decodeJSONArray decodes the data of an SSE event, which is usually a JSON
+// array but may be a single JSON object.
+func decodeJSONArray[T any](data []byte) ([]T, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] != '[' {
+		var v T
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		return []T{v}, nil
+	}
+	var v []T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// decodeDEEP decodes the data of a DEEP SSE event into updates.
+func decodeDEEP[T any](data []byte) ([]DEEPUpdate[T], error) {
+	msgs, err := decodeJSONArray[deepMessage](data)
+	if err != nil {
+		return nil, err
+	}
+	updates := make([]DEEPUpdate[T], 0, len(msgs))
+	for _, msg := range msgs {
+		u := DEEPUpdate[T]{Symbol: msg.Symbol, Seq: msg.Seq}
+		if err := json.Unmarshal(msg.Data, &u.Data); err != nil {
+			return nil, err
+		}
+		updates = append(updates, u)
+	}
+	return updates, nil
+}
+
+func deepUpdateSymbol[T any](u DEEPUpdate[T]) string {
+	return u.Symbol
+}
+
+// streamDEEP streams the updates of a DEEP sub-channel.
+func streamDEEP[T any](ctx context.Context, c *Client, channel string, symbols []string, opts []StreamOption) *Stream[DEEPUpdate[T]] {
+	return newStream(ctx, c.newStreamer(deepChannel(channel), symbols, opts), decodeDEEP[T], deepUpdateSymbol[T])
+}
+
+// StreamTOPS streams TOPS data for the given symbols.
+func (c Client) StreamTOPS(ctx context.Context, symbols []string, opts ...StreamOption) *Stream[TOPS] {
+	return newStream(ctx, c.newStreamer(sseChannel{name: "tops", path: "tops"}, symbols, opts),
+		decodeJSONArray[TOPS],
+		func(t TOPS) string { return t.Symbol },
+	)
+}
+
+// StreamLast streams Last data for the given symbols.
+func (c Client) StreamLast(ctx context.Context, symbols []string, opts ...StreamOption) *Stream[Last] {
+	return newStream(ctx, c.newStreamer(sseChannel{name: "last", path: "last"}, symbols, opts),
+		decodeJSONArray[Last],
+		func(l Last) string { return l.Symbol },
+	)
+}
+
+// StreamDEEP streams all DEEP data for the given symbols.
+func (c Client) StreamDEEP(ctx context.Context, symbols []string, opts ...StreamOption) *Stream[DEEP] {
+	decode := func(data []byte) ([]DEEP, error) {
+		updates, err := decodeDEEP[DEEP](data)
+		if err != nil {
+			return nil, err
+		}
+		deeps := make([]DEEP, len(updates))
+		for i, u := range updates {
+			deeps[i] = u.Data
+			if deeps[i].Symbol == "" {
+				deeps[i].Symbol = u.Symbol
+			}
+		}
+		return deeps, nil
+	}
+	return newStream(ctx, c.newStreamer(deepChannel("deep"), symbols, opts),
+		decode,
+		func(d DEEP) string { return d.Symbol },
+	)
+}
+
+// StreamDEEPBook streams DEEP book updates for the given symbols.
+func (c Client) StreamDEEPBook(ctx context.Context, symbols []string, opts ...StreamOption) *Stream[DEEPUpdate[DEEPBook]] {
+	return streamDEEP[DEEPBook](ctx, &c, "book", symbols, opts)
+}
+
+// StreamDEEPTrades streams DEEP trades for the given symbols.
+func (c Client) StreamDEEPTrades(ctx context.Context, symbols []string, opts ...StreamOption) *Stream[DEEPUpdate[Trade]] {
+	return streamDEEP[Trade](ctx, &c, "trades", symbols, opts)
+}
+
+// StreamDEEPSystemEvents streams DEEP system events.
+func (c Client) StreamDEEPSystemEvents(ctx context.Context, symbols []string, opts ...StreamOption) *Stream[DEEPUpdate[SystemEvent]] {
+	return streamDEEP[SystemEvent](ctx, &c, "system-event", symbols, opts)
+}
+
+// StreamDEEPTradingStatus streams DEEP trading status updates for the given
+// symbols.
+func (c Client) StreamDEEPTradingStatus(ctx context.Context, symbols []string, opts ...StreamOption) *Stream[DEEPUpdate[TradingStatus]] {
+	return streamDEEP[TradingStatus](ctx, &c, "trading-status", symbols, opts)
+}
+
+// StreamDEEPOpHaltStatus streams DEEP operational halt status updates for the
+// given symbols.
+func (c Client) StreamDEEPOpHaltStatus(ctx context.Context, symbols []string, opts ...StreamOption) *Stream[DEEPUpdate[OpHaltStatus]] {
+	return streamDEEP[OpHaltStatus](ctx, &c, "op-halt-status", symbols, opts)
+}
+
+// StreamDEEPSSRStatus streams DEEP short sale price test status updates for
+// the given symbols.
+func (c Client) StreamDEEPSSRStatus(ctx context.Context, symbols []string, opts ...StreamOption) *Stream[DEEPUpdate[SSRStatus]] {
+	return streamDEEP[SSRStatus](ctx, &c, "ssr-status", symbols, opts)
+}
+
+// StreamDEEPSecurityEvents streams DEEP security events for the given symbols.
+func (c Client) StreamDEEPSecurityEvents(ctx context.Context, symbols []string, opts ...StreamOption) *Stream[DEEPUpdate[SecurityEvent]] {
+	return streamDEEP[SecurityEvent](ctx, &c, "security-event", symbols, opts)
+}
+
+// StreamDEEPAuctions streams DEEP auction updates for the given symbols.
+func (c Client) StreamDEEPAuctions(ctx context.Context, symbols []string, opts ...StreamOption) *Stream[DEEPUpdate[Auction]] {
+	return streamDEEP[Auction](ctx, &c, "auction", symbols, opts)
+}
+
+// StreamNews streams news for the given symbols. News items have no symbol,
+// so OverflowCoalesce behaves like OverflowDropOldest.
+func (c Client) StreamNews(ctx context.Context, symbols []string, opts ...StreamOption) *Stream[News] {
+	return newStream(ctx, c.newStreamer(sseChannel{name: "news-stream", path: "news-stream"}, symbols, opts),
+		decodeJSONArray[News],
+		nil,
+	)
+}
+
+// StreamCurrencyRates streams foreign currency exchange rates for the given
+// currency pairs, such as "USDCAD".
+func (c Client) StreamCurrencyRates(ctx context.Context, symbols []string, opts ...StreamOption) *Stream[CurrencyRate] {
+	return newStream(ctx, c.newStreamer(sseChannel{name: "forex", path: "forex", intervals: true}, symbols, opts),
+		decodeJSONArray[CurrencyRate],
+		func(r CurrencyRate) string { return r.Symbol },
+	)
+}
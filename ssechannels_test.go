@@ -0,0 +1,189 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+// first returns the first item received by the stream and closes it.
+func first[T any](t *testing.T, s *Stream[T]) T {
+	t.Helper()
+	defer s.Close()
+	item, ok := <-s.C()
+	if !ok {
+		t.Fatalf("Stream ended with error %v, want an item", s.Err())
+	}
+	return item
+}
+
+func TestStreamChannels(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     string
+		open     func(t *testing.T, c *Client) any
+		wantPath string
+		wantDEEP string
+		want     any
+	}{
+		{
+			name: "tops",
+			data: `[{"symbol":"AAPL","bidPrice":1.5}]`,
+			open: func(t *testing.T, c *Client) any {
+				return first(t, c.StreamTOPS(context.Background(), []string{"aapl"}))
+			},
+			wantPath: "/tops",
+			want:     TOPS{Symbol: "AAPL", BidPrice: 1.5},
+		},
+		{
+			name: "last",
+			data: `[{"symbol":"AAPL","price":2.5,"size":100}]`,
+			open: func(t *testing.T, c *Client) any {
+				return first(t, c.StreamLast(context.Background(), []string{"aapl"}))
+			},
+			wantPath: "/last",
+			want:     Last{Symbol: "AAPL", Price: 2.5, Size: 100},
+		},
+		{
+			name: "deep",
+			data: `[{"symbol":"AAPL","messageType":"deep","data":{"volume":10},"seq":3}]`,
+			open: func(t *testing.T, c *Client) any {
+				return first(t, c.StreamDEEP(context.Background(), []string{"aapl"}))
+			},
+			wantPath: "/deep",
+			wantDEEP: "deep",
+			want:     DEEP{Symbol: "AAPL", Volume: 10},
+		},
+		{
+			name: "deep trades",
+			data: `[{"symbol":"AAPL","messageType":"trades","data":{"price":3.5,"size":5,"tradeId":7},"seq":4}]`,
+			open: func(t *testing.T, c *Client) any {
+				return first(t, c.StreamDEEPTrades(context.Background(), []string{"aapl"}))
+			},
+			wantPath: "/deep",
+			wantDEEP: "trades",
+			want:     DEEPUpdate[Trade]{Symbol: "AAPL", Seq: 4, Data: Trade{Price: 3.5, Size: 5, TradeID: 7}},
+		},
+		{
+			name: "deep book",
+			data: `{"symbol":"AAPL","messageType":"book","data":{"bids":[{"price":1,"size":2}]},"seq":5}`,
+			open: func(t *testing.T, c *Client) any {
+				return first(t, c.StreamDEEPBook(context.Background(), []string{"aapl"}))
+			},
+			wantPath: "/deep",
+			wantDEEP: "book",
+			want:     DEEPUpdate[DEEPBook]{Symbol: "AAPL", Seq: 5, Data: DEEPBook{Bids: []BidAsk{{Price: 1, Size: 2}}}},
+		},
+		{
+			name: "deep op halt status",
+			data: `[{"symbol":"AAPL","messageType":"opHaltStatus","data":{"isHalted":true},"seq":6}]`,
+			open: func(t *testing.T, c *Client) any {
+				return first(t, c.StreamDEEPOpHaltStatus(context.Background(), []string{"aapl"}))
+			},
+			wantPath: "/deep",
+			wantDEEP: "op-halt-status",
+			want:     DEEPUpdate[OpHaltStatus]{Symbol: "AAPL", Seq: 6, Data: OpHaltStatus{IsHalted: true}},
+		},
+		{
+			name: "news",
+			data: `[{"headline":"Headline","url":"https://example.com"}]`,
+			open: func(t *testing.T, c *Client) any {
+				return first(t, c.StreamNews(context.Background(), []string{"aapl"}))
+			},
+			wantPath: "/news-stream",
+			want:     News{Headline: "Headline", URL: "https://example.com"},
+		},
+		{
+			name: "forex",
+			data: `[{"symbol":"USDCAD","rate":1.25}]`,
+			open: func(t *testing.T, c *Client) any {
+				return first(t, c.StreamCurrencyRates(context.Background(), []string{"aapl"}))
+			},
+			wantPath: "/forex",
+			want:     CurrencyRate{Symbol: "USDCAD", Rate: 1.25},
+		},
+		{
+			name: "forex interval",
+			data: `[{"symbol":"USDCAD","rate":1.25}]`,
+			open: func(t *testing.T, c *Client) any {
+				return first(t, c.StreamCurrencyRates(context.Background(), []string{"aapl"}, WithInterval(Interval5Second)))
+			},
+			wantPath: "/forex5Second",
+			want:     CurrencyRate{Symbol: "USDCAD", Rate: 1.25},
+		},
+		{
+			name: "quote interval",
+			data: `[{"symbol":"AAPL","latestPrice":4.5}]`,
+			open: func(t *testing.T, c *Client) any {
+				return first(t, c.StreamQuotes(context.Background(), []string{"aapl"}, true, WithInterval(Interval1Second)))
+			},
+			wantPath: "/stocksUS1Second",
+			want:     Quote{Symbol: "AAPL", LatestPrice: 4.5},
+		},
+		{
+			name: "interval ignored",
+			data: `[{"symbol":"AAPL"}]`,
+			open: func(t *testing.T, c *Client) any {
+				return first(t, c.StreamTOPS(context.Background(), []string{"aapl"}, WithInterval(Interval1Minute)))
+			},
+			wantPath: "/tops",
+			want:     TOPS{Symbol: "AAPL"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var path, symbols, channels string
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				symbols = r.URL.Query().Get("symbols")
+				channels = r.URL.Query().Get("channels")
+				fmt.Fprintf(w, "data: %s\n\n", tc.data)
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+			}))
+			defer s.Close()
+			client := newSSETestClient(s)
+
+			got := tc.open(t, client)
+			if diff := deep.Equal(got, tc.want); diff != nil {
+				t.Error(diff)
+			}
+			if path != tc.wantPath {
+				t.Errorf("Got path %s, want %s", path, tc.wantPath)
+			}
+			if symbols != "aapl" {
+				t.Errorf("Got symbols %s, want aapl", symbols)
+			}
+			if channels != tc.wantDEEP {
+				t.Errorf("Got channels %q, want %q", channels, tc.wantDEEP)
+			}
+		})
+	}
+}
+
+func TestStreamChannelNames(t *testing.T) {
+	client := NewClient(testToken)
+	for _, tc := range []struct {
+		ch   sseChannel
+		opts []StreamOption
+		want string
+	}{
+		{quoteChannel(true), nil, "stocksUS"},
+		{quoteChannel(false), []StreamOption{WithInterval(Interval1Minute)}, "stocksUSNoUTP1Minute"},
+		{deepChannel("deep"), nil, "deep"},
+		{deepChannel("ssr-status"), []StreamOption{WithInterval(Interval1Second)}, "deep-ssr-status"},
+	} {
+		if got := client.newStreamer(tc.ch, nil, tc.opts).channel; got != tc.want {
+			t.Errorf("Got channel %s, want %s", got, tc.want)
+		}
+	}
+}
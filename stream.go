@@ -0,0 +1,265 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"sync"
+)
+
+// OverflowPolicy determines what a Stream does when its buffer is full.
+type OverflowPolicy int
+
+// Available overflow policies.
+const (
+	// OverflowBlock stops reading from the connection until the consumer
+	// catches up. Nothing is lost, but a slow consumer stalls the stream.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest buffered item to make room.
+	OverflowDropOldest
+	// OverflowCoalesce keeps only the latest item for each symbol, replacing
+	// a buffered item for the same symbol in place. If the buffer is full of
+	// other symbols, the oldest item is discarded.
+	OverflowCoalesce
+)
+
+// DefaultStreamBuffer is the buffer size of a Stream unless set using
+// WithBuffer.
+const DefaultStreamBuffer = 256
+
+// WithBuffer sets the number of items a Stream buffers for its consumer.
+func WithBuffer(size int) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.buffer = size
+	}
+}
+
+// WithOverflowPolicy sets what a Stream does when its buffer is full. By
+// default, streams use OverflowBlock.
+func WithOverflowPolicy(policy OverflowPolicy) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.overflow = policy
+	}
+}
+
+// Stream delivers the items received by an SSE stream on a channel. The
+// stream reconnects as needed until it is closed, its context is done, or it
+// fails.
+type Stream[T any] struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	c      chan T
+	buf    *streamBuffer[T]
+	done   chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// C returns the channel on which items are delivered. The channel is closed
+// once the stream has ended and, unless the stream was closed, all buffered
+// items have been delivered.
+func (s *Stream[T]) C() <-chan T {
+	return s.c
+}
+
+// Close stops the stream and discards any buffered items. It waits for the
+// connection to close and always returns nil.
+func (s *Stream[T]) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// Done returns a channel that is closed once the stream has ended.
+func (s *Stream[T]) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err reports why the stream ended. It returns nil while the stream is
+// running and after the stream is closed using Close. It returns the
+// context's error if the stream's context is done, and the error that caused
+// the stream to fail otherwise.
+func (s *Stream[T]) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// newStream starts a Stream that decodes the data of each event into items.
+// The key function returns the symbol of an item for OverflowCoalesce.
+func newStream[T any](
+	ctx context.Context,
+	s *streamer,
+	decode func(data []byte) ([]T, error),
+	key func(T) string,
+) *Stream[T] {
+	size := s.cfg.buffer
+	if size <= 0 {
+		size = DefaultStreamBuffer
+	}
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	st := &Stream[T]{
+		ctx:    ctx,
+		cancel: cancel,
+		c:      make(chan T),
+		buf:    newStreamBuffer(size, s.cfg.overflow, key),
+		done:   make(chan struct{}),
+	}
+	// Wake a producer blocked on a full buffer once the stream is stopped.
+	context.AfterFunc(ctx, st.buf.close)
+	delivered := make(chan struct{})
+	go func() {
+		defer close(delivered)
+		st.deliver()
+	}()
+	go func() {
+		err := s.run(ctx, func(data []byte) (int, error) {
+			items, err := decode(data)
+			if err != nil {
+				return 0, err
+			}
+			for _, item := range items {
+				st.buf.push(item)
+			}
+			return len(items), nil
+		})
+		if err == nil && ctx.Err() != nil {
+			err = parent.Err()
+		}
+		st.mu.Lock()
+		st.err = err
+		st.mu.Unlock()
+		st.buf.finish()
+		<-delivered
+		cancel()
+		close(st.done)
+	}()
+	return st
+}
+
+// deliver sends buffered items on the stream's channel until the buffer is
+// closed or finished and drained.
+func (s *Stream[T]) deliver() {
+	defer close(s.c)
+	for {
+		item, ok := s.buf.pop()
+		if !ok {
+			return
+		}
+		select {
+		case s.c <- item:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// streamBuffer is a bounded queue between an SSE connection and the consumer
+// of a Stream that applies an OverflowPolicy.
+type streamBuffer[T any] struct {
+	size   int
+	policy OverflowPolicy
+	key    func(T) string
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	items    []T
+	keys     []string
+	closed   bool
+	finished bool
+}
+
+func newStreamBuffer[T any](size int, policy OverflowPolicy, key func(T) string) *streamBuffer[T] {
+	b := &streamBuffer[T]{size: size, policy: policy, key: key}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// push adds an item to the buffer according to the overflow policy. With
+// OverflowBlock, it waits until there is room or the buffer is closed.
+func (b *streamBuffer[T]) push(item T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	if b.policy == OverflowCoalesce && b.key != nil {
+		k := b.key(item)
+		for i := range b.keys {
+			if b.keys[i] == k {
+				b.items[i] = item
+				return
+			}
+		}
+		if len(b.items) >= b.size {
+			b.dropOldest()
+		}
+		b.items = append(b.items, item)
+		b.keys = append(b.keys, k)
+		b.cond.Broadcast()
+		return
+	}
+	for b.policy == OverflowBlock && len(b.items) >= b.size && !b.closed {
+		b.cond.Wait()
+	}
+	// The buffer may have been closed while waiting.
+	if b.closed {
+		return
+	}
+	if len(b.items) >= b.size {
+		b.dropOldest()
+	}
+	b.items = append(b.items, item)
+	b.cond.Broadcast()
+}
+
+// dropOldest removes the oldest item. The lock must be held.
+func (b *streamBuffer[T]) dropOldest() {
+	var zero T
+	b.items[0] = zero
+	b.items = b.items[1:]
+	if len(b.keys) > 0 {
+		b.keys = b.keys[1:]
+	}
+}
+
+// pop removes and returns the oldest item, waiting until one is available.
+// It returns false once the buffer is closed, or finished and empty.
+func (b *streamBuffer[T]) pop() (T, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for len(b.items) == 0 && !b.closed && !b.finished {
+		b.cond.Wait()
+	}
+	var zero T
+	if b.closed || len(b.items) == 0 {
+		return zero, false
+	}
+	item := b.items[0]
+	b.dropOldest()
+	b.cond.Broadcast()
+	return item, true
+}
+
+// close discards the buffered items and wakes any waiters.
+func (b *streamBuffer[T]) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.items, b.keys = nil, nil
+	b.cond.Broadcast()
+}
+
+// finish marks that no more items will be pushed, so that pop returns false
+// once the buffer is drained.
+func (b *streamBuffer[T]) finish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.finished = true
+	b.cond.Broadcast()
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+type testItem struct {
+	Symbol string
+	N      int
+}
+
+func drain[T any](b *streamBuffer[T]) []T {
+	b.finish()
+	var items []T
+	for {
+		item, ok := b.pop()
+		if !ok {
+			return items
+		}
+		items = append(items, item)
+	}
+}
+
+func TestStreamBufferPolicies(t *testing.T) {
+	pushes := []testItem{{"A", 1}, {"B", 1}, {"A", 2}, {"C", 1}, {"D", 1}, {"C", 2}}
+	testCases := []struct {
+		name   string
+		policy OverflowPolicy
+		want   []testItem
+	}{
+		{"drop oldest", OverflowDropOldest, []testItem{{"C", 1}, {"D", 1}, {"C", 2}}},
+		{"coalesce", OverflowCoalesce, []testItem{{"B", 1}, {"C", 2}, {"D", 1}}},
+	}
+	for _, tc := range testCases {
+		b := newStreamBuffer(3, tc.policy, func(i testItem) string { return i.Symbol })
+		for _, item := range pushes {
+			b.push(item)
+		}
+		if diff := deep.Equal(drain(b), tc.want); diff != nil {
+			t.Errorf("%s: %v", tc.name, diff)
+		}
+	}
+}
+
+func TestStreamBufferClosed(t *testing.T) {
+	for _, policy := range []OverflowPolicy{OverflowBlock, OverflowDropOldest, OverflowCoalesce} {
+		b := newStreamBuffer(3, policy, func(i testItem) string { return i.Symbol })
+		b.close()
+		b.push(testItem{"A", 1})
+		if len(b.items) != 0 || len(b.keys) != 0 {
+			t.Errorf("Policy %v: Got %d items after close, want none", policy, len(b.items))
+		}
+	}
+}
+
+func TestStreamBufferBlocks(t *testing.T) {
+	b := newStreamBuffer[int](1, OverflowBlock, nil)
+	b.push(1)
+	pushed := make(chan struct{})
+	go func() {
+		b.push(2)
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+		t.Fatal("Push to a full buffer did not block")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if item, _ := b.pop(); item != 1 {
+		t.Errorf("Got %d, want 1", item)
+	}
+	<-pushed
+	if diff := deep.Equal(drain(b), []int{2}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Closing the buffer releases a blocked push.
+	b = newStreamBuffer[int](1, OverflowBlock, nil)
+	b.push(1)
+	pushed = make(chan struct{})
+	go func() {
+		b.push(2)
+		close(pushed)
+	}()
+	b.close()
+	<-pushed
+}
+
+func TestStreamQuotes(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(w, "data: [{\"symbol\": \"AAPL\", \"latestPrice\": %d}]\n\n", i)
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer s.Close()
+	client := newSSETestClient(s)
+
+	stream := client.StreamQuotes(context.Background(), []string{"aapl"}, false)
+	var prices []float64
+	for q := range stream.C() {
+		prices = append(prices, q.LatestPrice)
+		if len(prices) == 3 {
+			break
+		}
+	}
+	if err := stream.Close(); err != nil {
+		t.Errorf("Error closing stream: %s", err)
+	}
+	if diff := deep.Equal(prices, []float64{1, 2, 3}); diff != nil {
+		t.Error(diff)
+	}
+	if err := stream.Err(); err != nil {
+		t.Errorf("Got error %v after Close, want nil", err)
+	}
+	if _, ok := <-stream.C(); ok {
+		t.Error("Got open channel after Close, want closed")
+	}
+}
+
+func TestStreamErr(t *testing.T) {
+	t.Run("failed", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Test-injected error", http.StatusUnauthorized)
+		}))
+		defer s.Close()
+		client := newSSETestClient(s)
+
+		stream := client.StreamQuotes(context.Background(), []string{"aapl"}, false)
+		for range stream.C() {
+		}
+		<-stream.Done()
+		if err := stream.Err(); !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("Got error %v, want %v", err, ErrUnauthorized)
+		}
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+		defer s.Close()
+		client := newSSETestClient(s)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stream := client.StreamQuotes(ctx, []string{"aapl"}, false)
+		cancel()
+		for range stream.C() {
+		}
+		<-stream.Done()
+		if err := stream.Err(); err != context.Canceled {
+			t.Errorf("Got error %v, want %v", err, context.Canceled)
+		}
+	})
+}
+
+func TestStreamCloseWhileBlocked(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 10; i++ {
+			fmt.Fprintf(w, "data: [{\"symbol\": \"AAPL\"}]\n\n")
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer s.Close()
+	client := newSSETestClient(s)
+
+	stream := client.StreamQuotes(context.Background(), []string{"aapl"}, false, WithBuffer(1))
+	// Wait for the producer to fill the buffer and block.
+	time.Sleep(50 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		stream.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return while the stream was blocked")
+	}
+}
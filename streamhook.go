@@ -0,0 +1,89 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// StreamState is the connection state of an SSE stream.
+type StreamState int
+
+// Available stream states.
+const (
+	// StreamConnecting means the stream is making its first connection.
+	StreamConnecting StreamState = iota
+	// StreamLive means the stream is connected and receiving events.
+	StreamLive
+	// StreamReconnecting means the connection was lost and the stream is
+	// waiting to reconnect.
+	StreamReconnecting
+	// StreamClosed means the stream has ended because its context is done.
+	StreamClosed
+	// StreamFailed means the stream has ended because it could not
+	// reconnect.
+	StreamFailed
+)
+
+func (s StreamState) String() string {
+	switch s {
+	case StreamConnecting:
+		return "connecting"
+	case StreamLive:
+		return "live"
+	case StreamReconnecting:
+		return "reconnecting"
+	case StreamClosed:
+		return "closed"
+	case StreamFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// StreamEvent describes a change in the state of an SSE stream or a batch of
+// data received by the stream.
+type StreamEvent struct {
+	// ID identifies the stream within the process.
+	ID uint64
+	// Channel is the SSE channel, such as "stocksUSNoUTP".
+	Channel string
+	// Symbols are the symbols the stream is subscribed to.
+	Symbols []string
+	// State is the state of the stream.
+	State StreamState
+	// Batch is the number of items in a batch received while the stream is
+	// live. It is zero for changes in state.
+	Batch int
+	// Err is the error that caused the stream to reconnect or fail, if any.
+	Err error
+}
+
+// WithStreamHook adds a function that a new IEX Client calls whenever the
+// state of one of its SSE streams changes and whenever one of its streams
+// receives a batch of data. The context is the one passed to the streaming
+// method. Hooks are called synchronously and must not block.
+func WithStreamHook(hook func(ctx context.Context, ev StreamEvent)) ClientOption {
+	return func(client *Client) {
+		client.streamHooks = append(client.streamHooks, hook)
+	}
+}
+
+// lastStreamID is the ID of the most recently started stream.
+var lastStreamID uint64
+
+func nextStreamID() uint64 {
+	return atomic.AddUint64(&lastStreamID, 1)
+}
+
+// emitStreamEvent logs the event and calls the client's stream hooks with it.
+func (c *Client) emitStreamEvent(ctx context.Context, ev StreamEvent) {
+	c.logStreamEvent(ctx, ev)
+	for _, hook := range c.streamHooks {
+		hook(ctx, ev)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+func TestStreamHook(t *testing.T) {
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			http.Error(w, "Test-injected error", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("data: [{\"symbol\": \"AAPL\"}]\n\n"))
+	}))
+	defer s.Close()
+
+	var got []StreamEvent
+	client := NewClient(
+		testToken,
+		WithSSEBaseURL("http://"+s.Listener.Addr().String()),
+		WithStreamHook(func(_ context.Context, ev StreamEvent) {
+			if ev.ID == 0 {
+				t.Errorf("Got stream ID 0, want non-zero")
+			}
+			ev.ID = 0
+			ev.Err = nil
+			got = append(got, ev)
+		}),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := client.QuoteStream(ctx, []string{"aapl"}, false, func(quotes []Quote) { cancel() })
+	if err != nil {
+		t.Fatalf("Error streaming quotes: %s", err)
+	}
+
+	symbols := []string{"aapl"}
+	want := []StreamEvent{
+		{Channel: "stocksUSNoUTP", Symbols: symbols, State: StreamConnecting},
+		{Channel: "stocksUSNoUTP", Symbols: symbols, State: StreamReconnecting},
+		{Channel: "stocksUSNoUTP", Symbols: symbols, State: StreamLive},
+		{Channel: "stocksUSNoUTP", Symbols: symbols, State: StreamLive, Batch: 1},
+		{Channel: "stocksUSNoUTP", Symbols: symbols, State: StreamClosed},
+	}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Error(diff)
+	}
+}
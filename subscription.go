@@ -0,0 +1,425 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxStreamSymbols is the number of symbols a Subscription requests
+// per connection unless set using WithMaxSymbols.
+const DefaultMaxStreamSymbols = 50
+
+// ErrSubscriptionClosed is returned when changing the symbols of a
+// Subscription that has ended.
+var ErrSubscriptionClosed = errors.New("iex: subscription closed")
+
+// WithMaxSymbols sets the number of symbols a Subscription requests per
+// connection. Subscriptions with more symbols are spread over several
+// connections. The limit doesn't apply when replaying; see WithReplay.
+func WithMaxSymbols(n int) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.maxSymbols = n
+	}
+}
+
+// Subscription streams data for a set of symbols that can be changed while it
+// runs. The symbols are spread over as many connections as needed to respect
+// the per-connection symbol limit.
+//
+// When the symbols of a connection change, a new connection is opened with
+// the new symbols and the old connection is only closed once the new one is
+// live, so no updates are missed. Each symbol's updates are only delivered
+// from one connection at a time, and the first update for a symbol from a new
+// connection is skipped if it repeats the last update delivered, so the
+// switch causes no duplicate callbacks.
+//
+// When the client replays a recording using WithReplay, the subscription uses
+// one connection for its lifetime. The replay includes every symbol's events,
+// so changing the symbols only changes which events are delivered instead of
+// starting the replay over. The subscription ends at the end of the
+// recording, and Err then returns nil.
+type Subscription[T any] struct {
+	c        *Client
+	ch       sseChannel
+	decode   func(data []byte) ([]T, error)
+	key      func(T) string
+	callback func([]T)
+	opts     []StreamOption
+	max      int
+	replay   bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	done   chan struct{}
+
+	// deliverMu serializes callbacks.
+	deliverMu sync.Mutex
+
+	mu         sync.Mutex
+	closed     bool
+	err        error
+	shards     []*subShard
+	subscribed map[string]*subShard
+	owner      map[string]*subConn
+	last       map[string]T
+	handover   map[string]bool
+}
+
+// subShard is a group of symbols that share a connection.
+type subShard struct {
+	symbols []string
+	active  *subConn
+	pending *subConn
+}
+
+// subConn is one connection of a shard.
+type subConn struct {
+	symbols []string
+	cancel  context.CancelFunc
+}
+
+func newSubscription[T any](
+	ctx context.Context,
+	c *Client,
+	ch sseChannel,
+	decode func(data []byte) ([]T, error),
+	key func(T) string,
+	callback func([]T),
+	opts []StreamOption,
+) *Subscription[T] {
+	var cfg streamConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.maxSymbols <= 0 {
+		cfg.maxSymbols = DefaultMaxStreamSymbols
+	}
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Subscription[T]{
+		c:          c,
+		ch:         ch,
+		decode:     decode,
+		key:        key,
+		callback:   callback,
+		opts:       opts,
+		max:        cfg.maxSymbols,
+		replay:     c.replay != nil,
+		ctx:        ctx,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+		subscribed: make(map[string]*subShard),
+		owner:      make(map[string]*subConn),
+		last:       make(map[string]T),
+		handover:   make(map[string]bool),
+	}
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		s.closed = true
+		if s.err == nil {
+			s.err = parent.Err()
+		}
+		s.mu.Unlock()
+		s.wg.Wait()
+		close(s.done)
+	}()
+	return s
+}
+
+// Subscribe adds symbols to the subscription. Symbols that are already
+// subscribed are ignored.
+func (s *Subscription[T]) Subscribe(symbols ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSubscriptionClosed
+	}
+	changed := make(map[*subShard]bool)
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(symbol)
+		if _, ok := s.subscribed[symbol]; ok {
+			continue
+		}
+		var shard *subShard
+		for _, sh := range s.shards {
+			if len(sh.symbols) < s.max || s.replay {
+				shard = sh
+				break
+			}
+		}
+		if shard == nil {
+			shard = &subShard{}
+			s.shards = append(s.shards, shard)
+		}
+		shard.symbols = append(shard.symbols, symbol)
+		s.subscribed[symbol] = shard
+		changed[shard] = true
+	}
+	for _, shard := range s.shards {
+		if changed[shard] {
+			s.reopen(shard)
+		}
+	}
+	return nil
+}
+
+// Unsubscribe removes symbols from the subscription. No updates are delivered
+// for the symbols once Unsubscribe returns, so it waits for a callback in
+// progress to return and must not be called from the callback. Symbols that
+// aren't subscribed are ignored.
+func (s *Subscription[T]) Unsubscribe(symbols ...string) error {
+	s.deliverMu.Lock()
+	defer s.deliverMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSubscriptionClosed
+	}
+	changed := make(map[*subShard]bool)
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(symbol)
+		shard, ok := s.subscribed[symbol]
+		if !ok {
+			continue
+		}
+		delete(s.subscribed, symbol)
+		delete(s.owner, symbol)
+		delete(s.last, symbol)
+		delete(s.handover, symbol)
+		for i, sym := range shard.symbols {
+			if sym == symbol {
+				shard.symbols = append(shard.symbols[:i], shard.symbols[i+1:]...)
+				break
+			}
+		}
+		changed[shard] = true
+	}
+	shards := s.shards[:0]
+	for _, shard := range s.shards {
+		switch {
+		case !changed[shard]:
+		case len(shard.symbols) == 0 && !s.replay:
+			if shard.active != nil {
+				shard.active.cancel()
+			}
+			if shard.pending != nil {
+				shard.pending.cancel()
+			}
+			continue
+		default:
+			s.reopen(shard)
+		}
+		shards = append(shards, shard)
+	}
+	s.shards = shards
+	return nil
+}
+
+// Symbols returns the subscribed symbols in alphabetical order.
+func (s *Subscription[T]) Symbols() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	symbols := make([]string, 0, len(s.subscribed))
+	for symbol := range s.subscribed {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// Close stops the subscription and waits for its connections to close. It
+// must not be called from the callback. It always returns nil.
+func (s *Subscription[T]) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// Done returns a channel that is closed once the subscription has ended.
+func (s *Subscription[T]) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err reports why the subscription ended. It returns nil while the
+// subscription is running and after it is closed using Close. It returns the
+// context's error if the subscription's context is done, and the error that
+// caused one of its connections to fail otherwise.
+func (s *Subscription[T]) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// reopen opens a new connection for the shard's symbols, replacing any
+// pending connection. The lock must be held.
+func (s *Subscription[T]) reopen(shard *subShard) {
+	if s.replay && (shard.active != nil || shard.pending != nil) {
+		// A new replay connection would start the replay over, and the
+		// existing one already replays the new symbols' events.
+		conn := shard.active
+		if conn == nil {
+			conn = shard.pending
+		}
+		conn.symbols = append([]string(nil), shard.symbols...)
+		if conn == shard.active {
+			for _, symbol := range conn.symbols {
+				s.owner[symbol] = conn
+			}
+		}
+		return
+	}
+	if shard.pending != nil {
+		shard.pending.cancel()
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	conn := &subConn{
+		symbols: append([]string(nil), shard.symbols...),
+		cancel:  cancel,
+	}
+	shard.pending = conn
+
+	st := s.c.newStreamer(s.ch, conn.symbols, s.opts)
+	onStateChange := st.cfg.onStateChange
+	st.cfg.onStateChange = func(state StreamState, err error) {
+		if state == StreamLive {
+			s.promote(shard, conn)
+		}
+		if onStateChange != nil {
+			onStateChange(state, err)
+		}
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		err := st.run(ctx, func(data []byte) (int, error) {
+			items, err := s.decode(data)
+			if err != nil {
+				return 0, err
+			}
+			s.deliver(conn, items)
+			return len(items), nil
+		})
+		if err != nil {
+			s.mu.Lock()
+			if s.err == nil {
+				s.err = err
+			}
+			s.mu.Unlock()
+			s.cancel()
+		} else if s.replay && ctx.Err() == nil {
+			// The replay reached the end of the recording.
+			s.cancel()
+		}
+	}()
+}
+
+// promote makes a pending connection that has gone live the shard's active
+// connection and closes the connection it replaces.
+func (s *Subscription[T]) promote(shard *subShard, conn *subConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if shard.pending != conn {
+		return
+	}
+	if shard.active != nil {
+		shard.active.cancel()
+	}
+	shard.active, shard.pending = conn, nil
+	for _, symbol := range conn.symbols {
+		if s.subscribed[symbol] != shard {
+			continue
+		}
+		if s.owner[symbol] != nil {
+			s.handover[symbol] = true
+		}
+		s.owner[symbol] = conn
+	}
+}
+
+// deliver calls the callback with the items received by a connection for the
+// symbols it owns.
+func (s *Subscription[T]) deliver(conn *subConn, items []T) {
+	s.deliverMu.Lock()
+	defer s.deliverMu.Unlock()
+	s.mu.Lock()
+	var out []T
+	for _, item := range items {
+		k := strings.ToUpper(s.key(item))
+		if s.owner[k] != conn {
+			continue
+		}
+		if s.handover[k] {
+			delete(s.handover, k)
+			if last, ok := s.last[k]; ok && reflect.DeepEqual(last, item) {
+				continue
+			}
+		}
+		s.last[k] = item
+		out = append(out, item)
+	}
+	s.mu.Unlock()
+	if len(out) > 0 {
+		s.callback(out)
+	}
+}
+
+// SubscribeQuotes is like QuoteStream, but returns a Subscription whose
+// symbols can be changed while it runs. The callback is never called
+// concurrently. The options apply to each of the subscription's connections.
+func (c Client) SubscribeQuotes(
+	ctx context.Context,
+	symbols []string,
+	utp bool,
+	callback func(quotes []Quote),
+	opts ...StreamOption,
+) *Subscription[Quote] {
+	s := newSubscription(ctx, &c, quoteChannel(utp), decodeJSONArray[Quote],
+		func(q Quote) string { return q.Symbol },
+		callback, opts,
+	)
+	s.Subscribe(symbols...)
+	return s
+}
+
+// SubscribeTOPS returns a Subscription to TOPS data whose symbols can be
+// changed while it runs. The callback is never called concurrently.
+func (c Client) SubscribeTOPS(
+	ctx context.Context,
+	symbols []string,
+	callback func(tops []TOPS),
+	opts ...StreamOption,
+) *Subscription[TOPS] {
+	s := newSubscription(ctx, &c, sseChannel{name: "tops", path: "tops"}, decodeJSONArray[TOPS],
+		func(t TOPS) string { return t.Symbol },
+		callback, opts,
+	)
+	s.Subscribe(symbols...)
+	return s
+}
+
+// SubscribeLast returns a Subscription to Last data whose symbols can be
+// changed while it runs. The callback is never called concurrently.
+func (c Client) SubscribeLast(
+	ctx context.Context,
+	symbols []string,
+	callback func(last []Last),
+	opts ...StreamOption,
+) *Subscription[Last] {
+	s := newSubscription(ctx, &c, sseChannel{name: "last", path: "last"}, decodeJSONArray[Last],
+		func(l Last) string { return l.Symbol },
+		callback, opts,
+	)
+	s.Subscribe(symbols...)
+	return s
+}
@@ -0,0 +1,291 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+// subscriptionServer serves quote streams, writing the events given for a
+// connection's symbols and keeping track of the open connections.
+type subscriptionServer struct {
+	*httptest.Server
+	events map[string][]string
+
+	mu   sync.Mutex
+	open map[string]int
+}
+
+func newSubscriptionServer(events map[string][]string) *subscriptionServer {
+	s := &subscriptionServer{events: events, open: make(map[string]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		symbols := r.URL.Query().Get("symbols")
+		s.mu.Lock()
+		s.open[symbols]++
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			s.open[symbols]--
+			s.mu.Unlock()
+		}()
+		w.(http.Flusher).Flush()
+		for _, data := range s.events[symbols] {
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	return s
+}
+
+// waitOpen waits until the open connections are those for the given symbols.
+func (s *subscriptionServer) waitOpen(t *testing.T, want ...string) {
+	t.Helper()
+	sort.Strings(want)
+	var got []string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		got = nil
+		s.mu.Lock()
+		for symbols, n := range s.open {
+			for i := 0; i < n; i++ {
+				got = append(got, symbols)
+			}
+		}
+		s.mu.Unlock()
+		sort.Strings(got)
+		if deep.Equal(got, want) == nil {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Got open connections %q, want %q", got, want)
+}
+
+func quoteEvent(symbol string, price float64) string {
+	return fmt.Sprintf(`[{"symbol":%q,"latestPrice":%g}]`, symbol, price)
+}
+
+func TestSubscriptionShards(t *testing.T) {
+	s := newSubscriptionServer(nil)
+	defer s.Close()
+	client := newSSETestClient(s.Server)
+
+	sub := client.SubscribeQuotes(context.Background(), []string{"a", "b", "c", "d", "e"}, false,
+		func([]Quote) {}, WithMaxSymbols(2))
+	defer sub.Close()
+	s.waitOpen(t, "A,B", "C,D", "E")
+
+	if err := sub.Unsubscribe("c", "d", "x"); err != nil {
+		t.Fatalf("Error unsubscribing: %s", err)
+	}
+	s.waitOpen(t, "A,B", "E")
+
+	// New symbols fill the shards that have room.
+	if err := sub.Subscribe("f", "g", "a"); err != nil {
+		t.Fatalf("Error subscribing: %s", err)
+	}
+	s.waitOpen(t, "A,B", "E,F", "G")
+	if diff := deep.Equal(sub.Symbols(), []string{"A", "B", "E", "F", "G"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestSubscriptionSwitch(t *testing.T) {
+	s := newSubscriptionServer(map[string][]string{
+		"AAPL": {quoteEvent("AAPL", 1)},
+		// The new connection repeats the last AAPL quote before updating.
+		"AAPL,MSFT": {quoteEvent("AAPL", 1), quoteEvent("MSFT", 1), quoteEvent("AAPL", 2)},
+		"MSFT":      {quoteEvent("MSFT", 2)},
+	})
+	defer s.Close()
+	client := newSSETestClient(s.Server)
+
+	quotes := make(chan Quote, 10)
+	sub := client.SubscribeQuotes(context.Background(), []string{"aapl"}, false, func(qs []Quote) {
+		for _, q := range qs {
+			quotes <- q
+		}
+	})
+	defer sub.Close()
+	next := func() string {
+		select {
+		case q := <-quotes:
+			return fmt.Sprintf("%s %g", q.Symbol, q.LatestPrice)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for a quote")
+		}
+		return ""
+	}
+
+	var got []string
+	got = append(got, next())
+	if err := sub.Subscribe("msft"); err != nil {
+		t.Fatalf("Error subscribing: %s", err)
+	}
+	got = append(got, next(), next())
+	s.waitOpen(t, "AAPL,MSFT")
+	if err := sub.Unsubscribe("aapl"); err != nil {
+		t.Fatalf("Error unsubscribing: %s", err)
+	}
+	got = append(got, next())
+	s.waitOpen(t, "MSFT")
+
+	want := []string{"AAPL 1", "MSFT 1", "AAPL 2", "MSFT 2"}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Error(diff)
+	}
+	select {
+	case q := <-quotes:
+		t.Errorf("Got unexpected quote %v", q)
+	default:
+	}
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	s := newSubscriptionServer(nil)
+	defer s.Close()
+	client := newSSETestClient(s.Server)
+
+	sub := client.SubscribeQuotes(context.Background(), []string{"aapl"}, false, func([]Quote) {})
+	s.waitOpen(t, "AAPL")
+	if err := sub.Close(); err != nil {
+		t.Errorf("Error closing subscription: %s", err)
+	}
+	s.waitOpen(t)
+	if err := sub.Err(); err != nil {
+		t.Errorf("Got error %v after Close, want nil", err)
+	}
+	if err := sub.Subscribe("msft"); err != ErrSubscriptionClosed {
+		t.Errorf("Got error %v, want %v", err, ErrSubscriptionClosed)
+	}
+}
+
+func TestSubscriptionUnsubscribeWaitsForCallback(t *testing.T) {
+	s := newSubscriptionServer(map[string][]string{"AAPL": {quoteEvent("AAPL", 1)}})
+	defer s.Close()
+	client := newSSETestClient(s.Server)
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	sub := client.SubscribeQuotes(context.Background(), []string{"aapl"}, false, func([]Quote) {
+		close(entered)
+		<-release
+	})
+	defer sub.Close()
+	select {
+	case <-entered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a quote")
+	}
+
+	unsubscribed := make(chan error)
+	go func() {
+		unsubscribed <- sub.Unsubscribe("aapl")
+	}()
+	select {
+	case err := <-unsubscribed:
+		t.Errorf("Unsubscribe returned %v during a callback, want it to wait", err)
+		close(release)
+		return
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-unsubscribed; err != nil {
+		t.Errorf("Error unsubscribing: %s", err)
+	}
+}
+
+func TestSubscriptionReplayEnds(t *testing.T) {
+	client := NewClient("", WithReplay(writeRecording(t), ReplayAsFastAsPossible))
+	var prices []float64
+	sub := client.SubscribeQuotes(context.Background(), []string{"aapl"}, false, func(qs []Quote) {
+		for _, q := range qs {
+			prices = append(prices, q.LatestPrice)
+		}
+	})
+	select {
+	case <-sub.Done():
+	case <-time.After(5 * time.Second):
+		sub.Close()
+		t.Fatal("Timed out waiting for the subscription to end after the replay")
+	}
+	if err := sub.Err(); err != nil {
+		t.Errorf("Got error %v, want nil", err)
+	}
+	if diff := deep.Equal(prices, []float64{1, 2, 3}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestSubscriptionReplay(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "recording")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := NewRecorder(f)
+	start := time.Date(2024, 3, 1, 14, 30, 0, 0, time.UTC)
+	for _, ev := range []struct {
+		offset time.Duration
+		data   string
+	}{
+		{0, quoteEvent("MSFT", 1)},
+		{0, quoteEvent("AAPL", 1)},
+		{200 * time.Millisecond, quoteEvent("AAPL", 2)},
+		{200 * time.Millisecond, quoteEvent("MSFT", 2)},
+		{400 * time.Millisecond, quoteEvent("AAPL", 3)},
+	} {
+		rec.record(start.Add(ev.offset), "stocksUSNoUTP", []byte(ev.data))
+	}
+	f.Close()
+	if err := rec.Err(); err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient("", WithReplay(name, 1))
+
+	quotes := make(chan string, 10)
+	sub := client.SubscribeQuotes(context.Background(), []string{"aapl"}, false, func(qs []Quote) {
+		for _, q := range qs {
+			quotes <- fmt.Sprintf("%s %g", q.Symbol, q.LatestPrice)
+		}
+	})
+	defer sub.Close()
+	var got []string
+	for timeout := time.After(5 * time.Second); len(got) < 4; {
+		select {
+		case q := <-quotes:
+			got = append(got, q)
+			if len(got) == 1 {
+				// Adding a symbol continues the replay instead of starting
+				// it over.
+				if err := sub.Subscribe("msft"); err != nil {
+					t.Fatalf("Error subscribing: %s", err)
+				}
+			}
+		case <-timeout:
+			t.Fatalf("Timed out waiting for quotes, got %q", got)
+		}
+	}
+	select {
+	case q := <-quotes:
+		got = append(got, q)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if diff := deep.Equal(got, []string{"AAPL 1", "AAPL 2", "MSFT 2", "AAPL 3"}); diff != nil {
+		t.Error(diff)
+	}
+}
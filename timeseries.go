@@ -5,8 +5,27 @@
 
 package iex
 
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-querystring/query"
+)
+
+// TimeSeriesRange is the range of dates of a time series query, either a
+// calendar range, such as LastMonth, or a relative range made with
+// RelativeRange.
 type TimeSeriesRange string
 
+// Calendar ranges. The ranges in the future require Calendar to be set in
+// the TimeSeriesQueryParameters.
 const (
 	Today       TimeSeriesRange = "today"
 	Yesterday   TimeSeriesRange = "yesterday"
@@ -14,7 +33,259 @@ const (
 	LastWeek    TimeSeriesRange = "last-week"
 	LastMonth   TimeSeriesRange = "last-month"
 	LastQuarter TimeSeriesRange = "last-quarter"
+	LastYear    TimeSeriesRange = "last-year"
+	ThisWeek    TimeSeriesRange = "this-week"
+	ThisMonth   TimeSeriesRange = "this-month"
+	ThisQuarter TimeSeriesRange = "this-quarter"
+	ThisYear    TimeSeriesRange = "this-year"
+	Tomorrow    TimeSeriesRange = "tomorrow"
+	NextWeek    TimeSeriesRange = "next-week"
+	NextMonth   TimeSeriesRange = "next-month"
+	NextQuarter TimeSeriesRange = "next-quarter"
+	NextYear    TimeSeriesRange = "next-year"
+)
+
+// TimeSeriesUnit is the unit of a relative TimeSeriesRange.
+type TimeSeriesUnit string
+
+// Units of relative time series ranges.
+const (
+	TimeSeriesDays     TimeSeriesUnit = "d"
+	TimeSeriesWeeks    TimeSeriesUnit = "w"
+	TimeSeriesMonths   TimeSeriesUnit = "m"
+	TimeSeriesQuarters TimeSeriesUnit = "q"
+	TimeSeriesYears    TimeSeriesUnit = "y"
+)
+
+// RelativeRange returns the range of the last n units, such as five years
+// for RelativeRange(5, TimeSeriesYears).
+func RelativeRange(n int, unit TimeSeriesUnit) TimeSeriesRange {
+	return TimeSeriesRange(strconv.Itoa(n) + string(unit))
+}
+
+// TimeSeriesSort is the order of the records of a time series query.
+type TimeSeriesSort string
+
+// Time series sort orders. By default, records are in descending date order.
+const (
+	SortAscending  TimeSeriesSort = "asc"
+	SortDescending TimeSeriesSort = "desc"
 )
 
+// Subattributes filters time series records on the values of their fields.
+type Subattributes map[string]string
+
+// EncodeValues implements the query.Encoder interface, encoding the
+// subattributes as key|value pairs separated by commas.
+func (s Subattributes) EncodeValues(key string, v *url.Values) error {
+	if len(s) == 0 {
+		return nil
+	}
+	pairs := make([]string, 0, len(s))
+	for k, value := range s {
+		pairs = append(pairs, k+"|"+value)
+	}
+	sort.Strings(pairs)
+	v.Set(key, strings.Join(pairs, ","))
+	return nil
+}
+
+// TimeSeriesQueryParameters are the optional query parameters of a time
+// series query. Parameters that are zero aren't passed.
 type TimeSeriesQueryParameters struct {
+	// Range returns the records in a range of dates. It can't be combined
+	// with From, To, or On.
+	Range TimeSeriesRange `url:"range,omitempty"`
+	// Calendar allows ranges in the future, such as NextWeek.
+	Calendar bool `url:"calendar,omitempty"`
+	// From and To return the records between two dates, inclusive.
+	From time.Time `url:"from,omitempty" layout:"2006-01-02"`
+	To   time.Time `url:"to,omitempty" layout:"2006-01-02"`
+	// On returns the records on a date.
+	On time.Time `url:"on,omitempty" layout:"2006-01-02"`
+	// Last returns the last n records.
+	Last int `url:"last,omitempty"`
+	// First returns the first n records.
+	First int `url:"first,omitempty"`
+	// Interval returns every nth record.
+	Interval int `url:"interval,omitempty"`
+	// Sort sets the order of the records by date.
+	Sort TimeSeriesSort `url:"sort,omitempty"`
+	// Subattribute returns the records whose fields have the given values.
+	Subattribute Subattributes `url:"subattribute,omitempty"`
+	// DateField sets the field used for the date of the records, for
+	// datasets with several date fields.
+	DateField string `url:"dateField,omitempty"`
+	// Format sets the format of the response, such as "csv". TimeSeries
+	// requires the default JSON format; use TimeSeriesBytes for other
+	// formats.
+	Format string `url:"format,omitempty"`
+}
+
+// TimeSeriesRecord is a record of any time series dataset. The standard
+// fields of time series records are decoded into the struct fields, and any
+// other fields are kept in Fields.
+type TimeSeriesRecord struct {
+	ID      string
+	Key     string
+	Subkey  string
+	Date    EpochTime
+	Updated EpochTime
+	Fields  map[string]json.RawMessage
+}
+
+var timeSeriesStandardFields = []string{"id", "key", "subkey", "date", "updated"}
+
+// UnmarshalJSON implements the Unmarshaler interface for TimeSeriesRecord.
+func (r *TimeSeriesRecord) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*r = TimeSeriesRecord{}
+	targets := []interface{}{&r.ID, &r.Key, &r.Subkey, &r.Date, &r.Updated}
+	for i, name := range timeSeriesStandardFields {
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+		delete(fields, name)
+		if err := json.Unmarshal(raw, targets[i]); err != nil {
+			return fmt.Errorf("decoding time series field %s: %w", name, err)
+		}
+	}
+	if len(fields) > 0 {
+		r.Fields = fields
+	}
+	return nil
+}
+
+// MarshalJSON implements the Marshaler interface for TimeSeriesRecord.
+func (r TimeSeriesRecord) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]interface{}, len(r.Fields)+5)
+	for name, raw := range r.Fields {
+		fields[name] = raw
+	}
+	fields["id"] = r.ID
+	fields["key"] = r.Key
+	fields["subkey"] = r.Subkey
+	fields["date"] = r.Date
+	fields["updated"] = r.Updated
+	return json.Marshal(fields)
+}
+
+// Float returns the number in the named field, or false if the field is
+// missing or isn't a number.
+func (r TimeSeriesRecord) Float(name string) (float64, bool) {
+	var f float64
+	raw, ok := r.Fields[name]
+	if !ok || json.Unmarshal(raw, &f) != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+// String returns the string in the named field, or false if the field is
+// missing or isn't a string.
+func (r TimeSeriesRecord) String(name string) (string, bool) {
+	var s string
+	raw, ok := r.Fields[name]
+	if !ok || json.Unmarshal(raw, &s) != nil {
+		return "", false
+	}
+	return s, true
+}
+
+// Decode decodes the record, including its standard fields, into v, such as
+// a pointer to a struct for the dataset.
+func (r TimeSeriesRecord) Decode(v interface{}) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// TimeSeriesValue is a record of a time series dataset that has a single
+// value per date, such as the ENERGY and ECONOMIC datasets.
+type TimeSeriesValue struct {
+	Value   float64   `json:"value"`
+	ID      string    `json:"id"`
+	Source  string    `json:"source"`
+	Key     string    `json:"key"`
+	Subkey  string    `json:"subkey"`
+	Date    EpochTime `json:"date"`
+	Updated EpochTime `json:"updated"`
+}
+
+// timeSeriesEndpoint returns the endpoint of a time series query.
+func timeSeriesEndpoint(id, key, subkey string, params *TimeSeriesQueryParameters) (string, error) {
+	if id == "" {
+		return "", errors.New("time series id is required")
+	}
+	if key == "" && subkey != "" {
+		return "", errors.New("time series subkey requires a key")
+	}
+	endpoint := "/time-series/" + url.PathEscape(id)
+	if key != "" {
+		endpoint += "/" + url.PathEscape(key)
+	}
+	if subkey != "" {
+		endpoint += "/" + url.PathEscape(subkey)
+	}
+	if params == nil {
+		return endpoint, nil
+	}
+	v, err := query.Values(params)
+	if err != nil {
+		return "", err
+	}
+	if q := v.Encode(); q != "" {
+		endpoint += "?" + q
+	}
+	return endpoint, nil
+}
+
+// TimeSeries queries the time series dataset with the given id, key, and
+// subkey, and decodes the JSON response into v. The key and subkey may be
+// empty. Use a *[]TimeSeriesRecord for v to decode records of any dataset,
+// or a pointer to a slice of structs for the dataset.
+func (c Client) TimeSeries(
+	ctx context.Context,
+	id, key, subkey string,
+	params *TimeSeriesQueryParameters,
+	v interface{},
+) error {
+	endpoint, err := timeSeriesEndpoint(id, key, subkey, params)
+	if err != nil {
+		return err
+	}
+	return c.GetJSON(ctx, endpoint, v)
+}
+
+// TimeSeriesBytes is like TimeSeries, but returns the response as is, such
+// as for a query in CSV format.
+func (c Client) TimeSeriesBytes(
+	ctx context.Context,
+	id, key, subkey string,
+	params *TimeSeriesQueryParameters,
+) ([]byte, error) {
+	endpoint, err := timeSeriesEndpoint(id, key, subkey, params)
+	if err != nil {
+		return nil, err
+	}
+	return c.GetBytes(ctx, endpoint)
+}
+
+// timeSeriesValues queries the values of the time series dataset with the
+// given id and key in ascending date order.
+func (c Client) timeSeriesValues(
+	ctx context.Context,
+	id, key string,
+	params *TimeSeriesQueryParameters,
+) ([]TimeSeriesValue, error) {
+	params.Sort = SortAscending
+	var values []TimeSeriesValue
+	err := c.TimeSeries(ctx, id, key, "", params, &values)
+	return values, err
 }
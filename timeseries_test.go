@@ -0,0 +1,176 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/karagog/testutil-go/fakehttpserver"
+)
+
+func TestTimeSeriesEndpoint(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		id      string
+		key     string
+		subkey  string
+		params  *TimeSeriesQueryParameters
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "id only",
+			id:   "ENERGY",
+			want: "/time-series/ENERGY",
+		},
+		{
+			name:   "key and subkey",
+			id:     "REPORTED_FINANCIALS",
+			key:    "AAPL",
+			subkey: "10-Q",
+			params: &TimeSeriesQueryParameters{},
+			want:   "/time-series/REPORTED_FINANCIALS/AAPL/10-Q",
+		},
+		{
+			name: "range",
+			id:   "ENERGY",
+			key:  "DCOILWTICO",
+			params: &TimeSeriesQueryParameters{
+				Range:    RelativeRange(5, TimeSeriesYears),
+				Interval: 2,
+				Sort:     SortAscending,
+			},
+			want: "/time-series/ENERGY/DCOILWTICO?interval=2&range=5y&sort=asc",
+		},
+		{
+			name: "calendar range",
+			id:   "PREMIUM_WALLSTREETHORIZON_EARNINGS",
+			params: &TimeSeriesQueryParameters{
+				Range:    NextWeek,
+				Calendar: true,
+			},
+			want: "/time-series/PREMIUM_WALLSTREETHORIZON_EARNINGS?calendar=true&range=next-week",
+		},
+		{
+			name: "dates",
+			id:   "ENERGY",
+			params: &TimeSeriesQueryParameters{
+				From:      time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+				To:        time.Date(2020, 3, 4, 0, 0, 0, 0, time.UTC),
+				Last:      10,
+				First:     5,
+				DateField: "reportDate",
+				Format:    "csv",
+			},
+			want: "/time-series/ENERGY?dateField=reportDate&first=5&format=csv&from=2020-01-02&last=10&to=2020-03-04",
+		},
+		{
+			name: "on and subattribute",
+			id:   "ENERGY",
+			params: &TimeSeriesQueryParameters{
+				On:           time.Date(2021, 6, 7, 0, 0, 0, 0, time.UTC),
+				Subattribute: Subattributes{"source": "EIA", "frequency": "daily"},
+			},
+			want: "/time-series/ENERGY?on=2021-06-07&subattribute=frequency%7Cdaily%2Csource%7CEIA",
+		},
+		{
+			name:    "missing id",
+			wantErr: true,
+		},
+		{
+			name:    "subkey without key",
+			id:      "ENERGY",
+			subkey:  "daily",
+			wantErr: true,
+		},
+	} {
+		got, err := timeSeriesEndpoint(tc.id, tc.key, tc.subkey, tc.params)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: Got error %v, want error %t", tc.name, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: Got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTimeSeries(t *testing.T) {
+	fakeIEX := fakehttpserver.FakeHTTPServer{ResponseJSON: `[
+		{
+			"id": "ENERGY",
+			"key": "DCOILWTICO",
+			"subkey": "NONE",
+			"date": 1609459200000,
+			"updated": 1609545600000,
+			"value": 48.52,
+			"source": "FRED"
+		}
+	]`}
+	s := httptest.NewServer(http.HandlerFunc(fakeIEX.Handle))
+	defer s.Close()
+	client := NewClient(testToken, withBaseAddress(s))
+
+	var records []TimeSeriesRecord
+	err := client.TimeSeries(context.Background(), "ENERGY", "DCOILWTICO", "",
+		&TimeSeriesQueryParameters{Range: LastMonth}, &records)
+	if err != nil {
+		t.Fatalf("Error querying time series: %s", err)
+	}
+	if got, want := fakeIEX.LastURLReceived.Path, "/time-series/ENERGY/DCOILWTICO"; got != want {
+		t.Errorf("Got path %s, want %s", got, want)
+	}
+	wantQuery := url.Values{"range": {"last-month"}, "token": {testToken}}
+	if diff := deep.Equal(fakeIEX.LastURLReceived.Query(), wantQuery); diff != nil {
+		t.Errorf("query: %v", diff)
+	}
+	want := []TimeSeriesRecord{{
+		ID:      "ENERGY",
+		Key:     "DCOILWTICO",
+		Subkey:  "NONE",
+		Date:    EpochTime(time.Unix(1609459200, 0)),
+		Updated: EpochTime(time.Unix(1609545600, 0)),
+		Fields: map[string]json.RawMessage{
+			"value":  json.RawMessage("48.52"),
+			"source": json.RawMessage(`"FRED"`),
+		},
+	}}
+	if diff := deep.Equal(records, want); diff != nil {
+		t.Fatal(diff)
+	}
+
+	if v, ok := records[0].Float("value"); !ok || v != 48.52 {
+		t.Errorf("Got value %v, %t, want 48.52", v, ok)
+	}
+	if _, ok := records[0].Float("source"); ok {
+		t.Error("Got a number for source, want none")
+	}
+	if v, ok := records[0].String("source"); !ok || v != "FRED" {
+		t.Errorf("Got source %q, %t, want FRED", v, ok)
+	}
+
+	type oilPrice struct {
+		Key    string    `json:"key"`
+		Date   EpochTime `json:"date"`
+		Value  float64   `json:"value"`
+		Source string    `json:"source"`
+	}
+	var price oilPrice
+	if err := records[0].Decode(&price); err != nil {
+		t.Fatalf("Error decoding record: %s", err)
+	}
+	wantPrice := oilPrice{Key: "DCOILWTICO", Date: EpochTime(time.Unix(1609459200, 0)), Value: 48.52, Source: "FRED"}
+	if diff := deep.Equal(price, wantPrice); diff != nil {
+		t.Error(diff)
+	}
+}
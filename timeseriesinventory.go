@@ -0,0 +1,220 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TimeSeriesDataset describes a time series dataset listed in the inventory.
+type TimeSeriesDataset struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	// Key and Subkey describe the keys and subkeys of the dataset, such as
+	// "A valid symbol".
+	Key    string           `json:"key"`
+	Subkey string           `json:"subkey"`
+	Schema TimeSeriesSchema `json:"schema"`
+	// Weight is the number of messages used by each record.
+	Weight      int    `json:"weight"`
+	Created     string `json:"created"`
+	LastUpdated string `json:"lastUpdated"`
+}
+
+// TimeSeriesSchema is the JSON schema of the records of a dataset.
+type TimeSeriesSchema struct {
+	Type                 string                     `json:"type"`
+	Properties           map[string]TimeSeriesField `json:"properties"`
+	Required             []string                   `json:"required"`
+	AdditionalProperties bool                       `json:"additionalProperties"`
+}
+
+// Fields returns the names of the fields of the schema in alphabetical order.
+func (s TimeSeriesSchema) Fields() []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TimeSeriesField is the schema of a field of a dataset's records.
+type TimeSeriesField struct {
+	// Type is the JSON type of the field, such as "number" or "string".
+	Type string
+	// Nullable is true if the field may be null.
+	Nullable bool
+}
+
+// UnmarshalJSON implements the Unmarshaler interface for TimeSeriesField. The
+// type of the field may be one type or a list of types.
+func (f *TimeSeriesField) UnmarshalJSON(data []byte) error {
+	var aux struct {
+		Type json.RawMessage `json:"type"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	*f = TimeSeriesField{}
+	if len(aux.Type) == 0 {
+		return nil
+	}
+	var types []string
+	if err := json.Unmarshal(aux.Type, &types); err != nil {
+		var t string
+		if err := json.Unmarshal(aux.Type, &t); err != nil {
+			return fmt.Errorf("decoding time series field type: %w", err)
+		}
+		types = []string{t}
+	}
+	for _, t := range types {
+		if t == "null" {
+			f.Nullable = true
+		} else if f.Type == "" {
+			f.Type = t
+		}
+	}
+	return nil
+}
+
+// TimeSeriesInventory lists the available time series datasets.
+type TimeSeriesInventory []TimeSeriesDataset
+
+// TimeSeriesInventory returns the inventory of the time series datasets.
+func (c Client) TimeSeriesInventory(ctx context.Context) (TimeSeriesInventory, error) {
+	var inventory TimeSeriesInventory
+	err := c.GetJSON(ctx, "/time-series", &inventory)
+	return inventory, err
+}
+
+// Dataset returns the dataset with the given id, or false if there isn't one.
+func (inv TimeSeriesInventory) Dataset(id string) (TimeSeriesDataset, bool) {
+	for _, ds := range inv {
+		if ds.ID == id {
+			return ds, true
+		}
+	}
+	return TimeSeriesDataset{}, false
+}
+
+// Validate checks a time series query against the inventory before it is
+// made, so that a typo doesn't spend messages. It reports an unknown dataset
+// id, a date field or subattribute that isn't in the dataset's schema, and
+// parameters that can't be combined. The error joins every problem found.
+func (inv TimeSeriesInventory) Validate(id string, params *TimeSeriesQueryParameters) error {
+	ds, ok := inv.Dataset(id)
+	if !ok {
+		ids := make([]string, len(inv))
+		for i, ds := range inv {
+			ids[i] = ds.ID
+		}
+		return fmt.Errorf("unknown time series dataset %q%s", id, suggest(id, ids))
+	}
+	if params == nil {
+		return nil
+	}
+	var errs []error
+	fields := ds.Schema.Fields()
+	checkField := func(param, name string) {
+		if _, ok := ds.Schema.Properties[name]; !ok && len(ds.Schema.Properties) > 0 {
+			errs = append(errs, fmt.Errorf("%s: %s has no field %q%s", param, id, name, suggest(name, fields)))
+		}
+	}
+	if params.DateField != "" {
+		checkField("dateField", params.DateField)
+	}
+	subattributes := make([]string, 0, len(params.Subattribute))
+	for name := range params.Subattribute {
+		subattributes = append(subattributes, name)
+	}
+	sort.Strings(subattributes)
+	for _, name := range subattributes {
+		checkField("subattribute", name)
+	}
+	if params.Range != "" && !(params.From.IsZero() && params.To.IsZero() && params.On.IsZero()) {
+		errs = append(errs, errors.New("range can't be combined with from, to, or on"))
+	}
+	if !params.On.IsZero() && !(params.From.IsZero() && params.To.IsZero()) {
+		errs = append(errs, errors.New("on can't be combined with from or to"))
+	}
+	if !params.From.IsZero() && !params.To.IsZero() && params.To.Before(params.From) {
+		errs = append(errs, errors.New("to is before from"))
+	}
+	if params.First != 0 && params.Last != 0 {
+		errs = append(errs, errors.New("first can't be combined with last"))
+	}
+	if params.First < 0 || params.Last < 0 || params.Interval < 0 {
+		errs = append(errs, errors.New("first, last, and interval must not be negative"))
+	}
+	if params.Sort != "" && params.Sort != SortAscending && params.Sort != SortDescending {
+		errs = append(errs, fmt.Errorf("invalid sort %q", params.Sort))
+	}
+	return errors.Join(errs...)
+}
+
+// suggest returns a suggestion of the closest of the candidates to a
+// misspelled name, or an empty string if none is close.
+func suggest(name string, candidates []string) string {
+	best, bestDist := "", 3
+	for _, c := range candidates {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(c)); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %q?)", best)
+}
+
+// editDistance returns the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// TimeSeriesDateRange returns the dates of the first and last records of the
+// time series with the given id, key, and subkey. It makes two queries of one
+// record each. The dates are zero if there are no records.
+func (c Client) TimeSeriesDateRange(ctx context.Context, id, key, subkey string) (first, last time.Time, err error) {
+	var records []TimeSeriesRecord
+	if err := c.TimeSeries(ctx, id, key, subkey, &TimeSeriesQueryParameters{First: 1}, &records); err != nil {
+		return first, last, err
+	}
+	if len(records) > 0 {
+		first = time.Time(records[0].Date)
+	}
+	records = nil
+	if err := c.TimeSeries(ctx, id, key, subkey, &TimeSeriesQueryParameters{Last: 1}, &records); err != nil {
+		return first, last, err
+	}
+	if len(records) > 0 {
+		last = time.Time(records[0].Date)
+	}
+	return first, last, nil
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/karagog/testutil-go/fakehttpserver"
+)
+
+const testInventoryJSON = `[
+	{
+		"id": "ENERGY",
+		"description": "Energy prices",
+		"key": "A valid energy series",
+		"subkey": "",
+		"schema": {
+			"type": "object",
+			"properties": {
+				"value": {"type": "number"},
+				"source": {"type": ["string", "null"]},
+				"reportDate": {"type": "string"}
+			},
+			"required": ["value"],
+			"additionalProperties": false
+		},
+		"weight": 1000,
+		"created": "2020-01-02 03:04:05",
+		"lastUpdated": "2024-01-02 03:04:05"
+	}
+]`
+
+func testInventory(t *testing.T) TimeSeriesInventory {
+	t.Helper()
+	fakeIEX := fakehttpserver.FakeHTTPServer{ResponseJSON: testInventoryJSON}
+	s := httptest.NewServer(http.HandlerFunc(fakeIEX.Handle))
+	defer s.Close()
+	client := NewClient(testToken, withBaseAddress(s))
+
+	inv, err := client.TimeSeriesInventory(context.Background())
+	if err != nil {
+		t.Fatalf("Error getting time series inventory: %s", err)
+	}
+	if got, want := fakeIEX.LastURLReceived.Path, "/time-series"; got != want {
+		t.Errorf("Got path %s, want %s", got, want)
+	}
+	return inv
+}
+
+func TestTimeSeriesInventory(t *testing.T) {
+	inv := testInventory(t)
+	want := TimeSeriesInventory{{
+		ID:          "ENERGY",
+		Description: "Energy prices",
+		Key:         "A valid energy series",
+		Schema: TimeSeriesSchema{
+			Type: "object",
+			Properties: map[string]TimeSeriesField{
+				"value":      {Type: "number"},
+				"source":     {Type: "string", Nullable: true},
+				"reportDate": {Type: "string"},
+			},
+			Required: []string{"value"},
+		},
+		Weight:      1000,
+		Created:     "2020-01-02 03:04:05",
+		LastUpdated: "2024-01-02 03:04:05",
+	}}
+	if diff := deep.Equal(inv, want); diff != nil {
+		t.Fatal(diff)
+	}
+	if diff := deep.Equal(inv[0].Schema.Fields(), []string{"reportDate", "source", "value"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestTimeSeriesInventoryValidate(t *testing.T) {
+	inv := testInventory(t)
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name     string
+		id       string
+		params   *TimeSeriesQueryParameters
+		wantErrs []string
+	}{
+		{name: "valid", id: "ENERGY", params: &TimeSeriesQueryParameters{
+			Range:        LastMonth,
+			DateField:    "reportDate",
+			Subattribute: Subattributes{"source": "EIA"},
+			Sort:         SortAscending,
+		}},
+		{name: "nil params", id: "ENERGY"},
+		{name: "unknown dataset", id: "ENERGYY", wantErrs: []string{`unknown time series dataset "ENERGYY" (did you mean "ENERGY"?)`}},
+		{name: "unknown dataset without suggestion", id: "CORE_ESTIMATES", wantErrs: []string{`unknown time series dataset "CORE_ESTIMATES"`}},
+		{name: "unknown fields", id: "ENERGY", params: &TimeSeriesQueryParameters{
+			DateField:    "reportdate",
+			Subattribute: Subattributes{"sauce": "EIA", "frequency": "daily"},
+		}, wantErrs: []string{
+			`dateField: ENERGY has no field "reportdate" (did you mean "reportDate"?)`,
+			`subattribute: ENERGY has no field "frequency"`,
+			`subattribute: ENERGY has no field "sauce" (did you mean "source"?)`,
+		}},
+		{name: "conflicting parameters", id: "ENERGY", params: &TimeSeriesQueryParameters{
+			Range: LastWeek,
+			From:  day,
+			To:    day.AddDate(0, 0, -1),
+			First: 1,
+			Last:  1,
+			Sort:  "up",
+		}, wantErrs: []string{
+			"range can't be combined with from, to, or on",
+			"to is before from",
+			"first can't be combined with last",
+			`invalid sort "up"`,
+		}},
+	} {
+		err := inv.Validate(tc.id, tc.params)
+		var got []string
+		if err != nil {
+			got = strings.Split(err.Error(), "\n")
+		}
+		if diff := deep.Equal(got, tc.wantErrs); diff != nil {
+			t.Errorf("%s: %v", tc.name, diff)
+		}
+	}
+}
+
+func TestTimeSeriesDateRange(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		date := int64(1577836800000) // 2020-01-01
+		if r.URL.Query().Get("last") == "1" {
+			date = 1704067200000 // 2024-01-01
+		}
+		fmt.Fprintf(w, `[{"id":"ENERGY","key":"DCOILWTICO","date":%d}]`, date)
+	}))
+	defer s.Close()
+	client := NewClient(testToken, withBaseAddress(s))
+
+	first, last, err := client.TimeSeriesDateRange(context.Background(), "ENERGY", "DCOILWTICO", "")
+	if err != nil {
+		t.Fatalf("Error getting date range: %s", err)
+	}
+	if want := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC); !first.Equal(want) {
+		t.Errorf("Got first %v, want %v", first, want)
+	}
+	if want := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); !last.Equal(want) {
+		t.Errorf("Got last %v, want %v", last, want)
+	}
+}
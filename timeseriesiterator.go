@@ -0,0 +1,269 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultTimeSeriesWindowDays is the number of days in each window fetched
+// by a TimeSeriesIterator unless set in the TimeSeriesIteratorOptions.
+const DefaultTimeSeriesWindowDays = 365
+
+// ErrTimeSeriesTruncated is returned by a TimeSeriesIterator when a window of
+// one day returns at least MaxRecords records, so some records may be missing
+// and the window can't be split any further.
+var ErrTimeSeriesTruncated = errors.New("iex: time series window truncated")
+
+// TimeSeriesIteratorOptions configures a TimeSeriesIterator.
+type TimeSeriesIteratorOptions struct {
+	// WindowDays is the number of days in each query. It defaults to
+	// DefaultTimeSeriesWindowDays.
+	WindowDays int
+	// Concurrency is the number of windows fetched at once. It defaults to
+	// one, which fetches the windows sequentially.
+	Concurrency int
+	// MaxRecords is the largest number of records the server returns for one
+	// query. A window that returns this many records may have been cut
+	// short, so it is split in half and each half is fetched instead. A
+	// window of one day can't be split, so the iteration stops with
+	// ErrTimeSeriesTruncated. Zero disables splitting.
+	MaxRecords int
+}
+
+// TimeSeriesIterator iterates over the records of a time series between two
+// dates in ascending date order, splitting the range into windows that are
+// each fetched with one query. Records repeated at the boundary of two
+// windows are returned once.
+//
+//	it := client.IterateTimeSeries(ctx, "ENERGY", "DCOILWTICO", "", params, nil)
+//	defer it.Close()
+//	for it.Next() {
+//		record := it.Record()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type TimeSeriesIterator struct {
+	c      Client
+	ctx    context.Context
+	cancel context.CancelFunc
+	id     string
+	key    string
+	subkey string
+	params TimeSeriesQueryParameters
+	opts   TimeSeriesIteratorOptions
+
+	windows [][2]time.Time
+	started int
+	pending []chan windowResult
+
+	records  []TimeSeriesRecord
+	record   TimeSeriesRecord
+	lastDate time.Time
+	seen     map[string]bool
+	err      error
+}
+
+type windowResult struct {
+	records []TimeSeriesRecord
+	err     error
+}
+
+// IterateTimeSeries returns an iterator over the records of the time series
+// with the given id, key, and subkey from params.From to params.To, or today
+// if To is zero. The other parameters, such as Subattribute and DateField,
+// apply to each query. Range, On, First, Last, and Interval can't be used.
+// If opts is nil, the defaults are used.
+func (c Client) IterateTimeSeries(
+	ctx context.Context,
+	id, key, subkey string,
+	params *TimeSeriesQueryParameters,
+	opts *TimeSeriesIteratorOptions,
+) *TimeSeriesIterator {
+	ctx, cancel := context.WithCancel(ctx)
+	it := &TimeSeriesIterator{
+		c:      c,
+		ctx:    ctx,
+		cancel: cancel,
+		id:     id,
+		key:    key,
+		subkey: subkey,
+		seen:   make(map[string]bool),
+	}
+	if params != nil {
+		it.params = *params
+	}
+	if opts != nil {
+		it.opts = *opts
+	}
+	if it.opts.WindowDays <= 0 {
+		it.opts.WindowDays = DefaultTimeSeriesWindowDays
+	}
+	if it.opts.Concurrency <= 0 {
+		it.opts.Concurrency = 1
+	}
+	p := it.params
+	switch {
+	case p.From.IsZero():
+		it.err = errors.New("time series iterator requires from")
+	case p.Range != "" || !p.On.IsZero() || p.First != 0 || p.Last != 0 || p.Interval != 0:
+		it.err = errors.New("time series iterator can't use range, on, first, last, or interval")
+	case p.Format != "" && p.Format != "json":
+		it.err = errors.New("time series iterator requires the JSON format")
+	}
+	if it.err != nil {
+		cancel()
+		return it
+	}
+	to := p.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	from, to := truncateDay(p.From), truncateDay(to)
+	for start := from; !start.After(to); {
+		end := start.AddDate(0, 0, it.opts.WindowDays-1)
+		if end.After(to) {
+			end = to
+		}
+		it.windows = append(it.windows, [2]time.Time{start, end})
+		start = end.AddDate(0, 0, 1)
+	}
+	it.params.Sort = SortAscending
+	return it
+}
+
+// truncateDay returns the date of t at midnight UTC.
+func truncateDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Next advances to the next record, which is then returned by Record. It
+// returns false once there are no more records or an error occurred.
+func (it *TimeSeriesIterator) Next() bool {
+	for {
+		if it.err != nil {
+			return false
+		}
+		for len(it.records) > 0 {
+			r := it.records[0]
+			it.records = it.records[1:]
+			if it.duplicate(r) {
+				continue
+			}
+			it.record = r
+			return true
+		}
+		if !it.nextWindow() {
+			it.cancel()
+			return false
+		}
+	}
+}
+
+// duplicate reports whether the record was already returned, which happens
+// when a record at the boundary of two windows is in both.
+func (it *TimeSeriesIterator) duplicate(r TimeSeriesRecord) bool {
+	date := time.Time(r.Date)
+	if date.Before(it.lastDate) {
+		return true
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return false
+	}
+	k := string(b)
+	if date.After(it.lastDate) {
+		it.lastDate = date
+		clear(it.seen)
+	} else if it.seen[k] {
+		return true
+	}
+	it.seen[k] = true
+	return false
+}
+
+// nextWindow waits for the records of the next window, starting the fetches
+// of the windows after it up to the concurrency. It returns false if there
+// are no more windows or the fetch failed.
+func (it *TimeSeriesIterator) nextWindow() bool {
+	for it.started < len(it.windows) && len(it.pending) < it.opts.Concurrency {
+		ch := make(chan windowResult, 1)
+		w := it.windows[it.started]
+		go func() {
+			records, err := it.fetch(w[0], w[1])
+			ch <- windowResult{records, err}
+		}()
+		it.pending = append(it.pending, ch)
+		it.started++
+	}
+	if len(it.pending) == 0 {
+		return false
+	}
+	res := <-it.pending[0]
+	it.pending = it.pending[1:]
+	if res.err != nil {
+		it.err = res.err
+		return false
+	}
+	it.records = res.records
+	return true
+}
+
+// fetch queries the records of a window, splitting it if the response may
+// have been cut short.
+func (it *TimeSeriesIterator) fetch(from, to time.Time) ([]TimeSeriesRecord, error) {
+	params := it.params
+	params.From, params.To = from, to
+	var records []TimeSeriesRecord
+	if err := it.c.TimeSeries(it.ctx, it.id, it.key, it.subkey, &params, &records); err != nil {
+		return nil, err
+	}
+	days := int(to.Sub(from).Hours() / 24)
+	if it.opts.MaxRecords > 0 && len(records) >= it.opts.MaxRecords {
+		if days == 0 {
+			return nil, fmt.Errorf("%w: %d records on %s", ErrTimeSeriesTruncated, len(records), from.Format("2006-01-02"))
+		}
+		mid := from.AddDate(0, 0, days/2)
+		first, err := it.fetch(from, mid)
+		if err != nil {
+			return nil, err
+		}
+		second, err := it.fetch(mid.AddDate(0, 0, 1), to)
+		if err != nil {
+			return nil, err
+		}
+		records = append(first, second...)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return time.Time(records[i].Date).Before(time.Time(records[j].Date))
+	})
+	return records, nil
+}
+
+// Record returns the current record.
+func (it *TimeSeriesIterator) Record() TimeSeriesRecord {
+	return it.record
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *TimeSeriesIterator) Err() error {
+	return it.err
+}
+
+// Close stops any fetches in progress. It should be called if the iteration
+// is stopped early, and always returns nil.
+func (it *TimeSeriesIterator) Close() error {
+	it.cancel()
+	return nil
+}
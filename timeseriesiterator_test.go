@@ -0,0 +1,186 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+)
+
+// timeSeriesServer serves a daily dataset with one record per day in
+// January 2020. Like a server that treats the window boundaries loosely, it
+// also returns the record of the day before from, and returns the records in
+// descending order.
+type timeSeriesServer struct {
+	*httptest.Server
+	maxRecords int
+
+	mu          sync.Mutex
+	requests    []string
+	inFlight    int
+	maxInFlight int
+}
+
+func newTimeSeriesServer(maxRecords int) *timeSeriesServer {
+	s := &timeSeriesServer{maxRecords: maxRecords}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		s.mu.Lock()
+		s.requests = append(s.requests, q.Get("from")+".."+q.Get("to"))
+		s.inFlight++
+		s.maxInFlight = max(s.maxInFlight, s.inFlight)
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			s.inFlight--
+			s.mu.Unlock()
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		from, _ := time.Parse("2006-01-02", q.Get("from"))
+		to, _ := time.Parse("2006-01-02", q.Get("to"))
+		var records []string
+		for d := to; !d.Before(from.AddDate(0, 0, -1)); d = d.AddDate(0, 0, -1) {
+			if d.Month() != time.January || len(records) == s.maxRecords && s.maxRecords > 0 {
+				continue
+			}
+			records = append(records, fmt.Sprintf(`{"id":"TEST","key":"K","date":%d,"value":%d}`,
+				d.UnixMilli(), d.Day()))
+		}
+		fmt.Fprintf(w, "[%s]", strings.Join(records, ","))
+	}))
+	return s
+}
+
+func collectTimeSeries(it *TimeSeriesIterator) []int {
+	var days []int
+	for it.Next() {
+		v, _ := it.Record().Float("value")
+		days = append(days, int(v))
+	}
+	return days
+}
+
+func TestTimeSeriesIterator(t *testing.T) {
+	s := newTimeSeriesServer(0)
+	defer s.Close()
+	client := NewClient(testToken, withBaseAddress(s.Server))
+
+	params := &TimeSeriesQueryParameters{
+		From: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2020, 1, 10, 0, 0, 0, 0, time.UTC),
+	}
+	it := client.IterateTimeSeries(context.Background(), "TEST", "K", "", params,
+		&TimeSeriesIteratorOptions{WindowDays: 3, Concurrency: 2})
+	defer it.Close()
+	days := collectTimeSeries(it)
+	if err := it.Err(); err != nil {
+		t.Fatalf("Error iterating: %s", err)
+	}
+	if diff := deep.Equal(days, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}); diff != nil {
+		t.Error(diff)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) != 4 {
+		t.Errorf("Got requests %q, want 4", s.requests)
+	}
+	if s.maxInFlight != 2 {
+		t.Errorf("Got %d concurrent requests, want 2", s.maxInFlight)
+	}
+}
+
+func TestTimeSeriesIteratorSplitsWindows(t *testing.T) {
+	s := newTimeSeriesServer(4)
+	defer s.Close()
+	client := NewClient(testToken, withBaseAddress(s.Server))
+
+	params := &TimeSeriesQueryParameters{
+		From: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:   time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC),
+	}
+	it := client.IterateTimeSeries(context.Background(), "TEST", "K", "", params,
+		&TimeSeriesIteratorOptions{WindowDays: 10, MaxRecords: 4})
+	defer it.Close()
+	days := collectTimeSeries(it)
+	if err := it.Err(); err != nil {
+		t.Fatalf("Error iterating: %s", err)
+	}
+	if diff := deep.Equal(days, []int{1, 2, 3, 4, 5, 6}); diff != nil {
+		t.Error(diff)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The second half is split again because of the extra boundary record.
+	want := []string{
+		"2020-01-01..2020-01-06",
+		"2020-01-01..2020-01-03",
+		"2020-01-04..2020-01-06",
+		"2020-01-04..2020-01-05",
+		"2020-01-06..2020-01-06",
+	}
+	if diff := deep.Equal(s.requests, want); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestTimeSeriesIteratorTruncated(t *testing.T) {
+	s := newTimeSeriesServer(2)
+	defer s.Close()
+	client := NewClient(testToken, withBaseAddress(s.Server))
+
+	// The server returns the day before as well, so a one-day window returns
+	// two records.
+	day := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	it := client.IterateTimeSeries(context.Background(), "TEST", "K", "",
+		&TimeSeriesQueryParameters{From: day, To: day}, &TimeSeriesIteratorOptions{MaxRecords: 2})
+	defer it.Close()
+	if days := collectTimeSeries(it); len(days) != 0 {
+		t.Errorf("Got records %v, want none", days)
+	}
+	if err := it.Err(); !errors.Is(err, ErrTimeSeriesTruncated) {
+		t.Errorf("Got error %v, want %v", err, ErrTimeSeriesTruncated)
+	}
+}
+
+func TestTimeSeriesIteratorErrors(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Test-injected error", http.StatusNotFound)
+	}))
+	defer s.Close()
+	client := NewClient(testToken, withBaseAddress(s))
+
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name    string
+		params  *TimeSeriesQueryParameters
+		wantErr error
+	}{
+		{"missing from", nil, nil},
+		{"range", &TimeSeriesQueryParameters{From: from, Range: LastMonth}, nil},
+		{"csv", &TimeSeriesQueryParameters{From: from, Format: "csv"}, nil},
+		{"request failed", &TimeSeriesQueryParameters{From: from, To: from}, ErrNotFound},
+	} {
+		it := client.IterateTimeSeries(context.Background(), "TEST", "", "", tc.params, nil)
+		if it.Next() {
+			t.Errorf("%s: Got a record, want none", tc.name)
+		}
+		err := it.Err()
+		if err == nil || (tc.wantErr != nil && !errors.Is(err, tc.wantErr)) {
+			t.Errorf("%s: Got error %v, want %v", tc.name, err, tc.wantErr)
+		}
+		it.Close()
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// WithSecretToken sets the secret (SK) token for a new IEX Client. The secret
+// token is used for the account endpoints, while the token given to NewClient
+// is used for everything else. If no secret token is set, the account
+// endpoints use the token given to NewClient.
+func WithSecretToken(token string) ClientOption {
+	return func(client *Client) {
+		client.secretToken = token
+	}
+}
+
+type tokenKey struct{}
+
+// ContextWithToken returns a copy of the context that makes requests use the
+// given token instead of the client's tokens. This allows a single Client to
+// make requests on behalf of different IEX Cloud accounts.
+func ContextWithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+// tokenFor returns the token to use for a request made with the context.
+func (c *Client) tokenFor(ctx context.Context) string {
+	if token, ok := ctx.Value(tokenKey{}).(string); ok {
+		return token
+	}
+	return c.token
+}
+
+// secretTokenFor returns the token to use for a request to an account
+// endpoint made with the context.
+func (c *Client) secretTokenFor(ctx context.Context) string {
+	if token, ok := ctx.Value(tokenKey{}).(string); ok {
+		return token
+	}
+	if c.secretToken != "" {
+		return c.secretToken
+	}
+	return c.token
+}
+
+// sharedKey returns the key under which a GET request's response may be
+// shared with other requests by the cache and the coalescer, or false if the
+// response must not be shared. Account responses are never shared. Requests
+// made with a token other than the client's own, such as one set using
+// ContextWithToken, are keyed by a hash of the token as well, so that
+// responses fetched for one account are never served to another.
+func (c *Client) sharedKey(req *Request) (string, bool) {
+	if req.Family() == "account" {
+		return "", false
+	}
+	key := req.URL.String()
+	if req.token != c.token {
+		sum := sha256.Sum256([]byte(req.token))
+		key += "#" + hex.EncodeToString(sum[:16])
+	}
+	return key, true
+}
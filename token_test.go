@@ -0,0 +1,181 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package iex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTokens(t *testing.T) {
+	const secretToken = "not-a-real-secret-token"
+	const tenantToken = "not-a-real-tenant-token"
+
+	var gotToken string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotToken = r.URL.Query().Get("token")
+		if r.Method == "POST" {
+			var body struct{ Token string }
+			json.NewDecoder(r.Body).Decode(&body)
+			gotToken = body.Token
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer s.Close()
+
+	testCases := []struct {
+		name      string
+		opts      []ClientOption
+		ctx       context.Context
+		call      func(c *Client, ctx context.Context) error
+		wantToken string
+	}{
+		{
+			name:      "publishable token for data endpoints",
+			opts:      []ClientOption{WithSecretToken(secretToken)},
+			ctx:       context.Background(),
+			call:      func(c *Client, ctx context.Context) error { _, err := c.Quote(ctx, "aapl"); return err },
+			wantToken: testToken,
+		},
+		{
+			name:      "secret token for account metadata",
+			opts:      []ClientOption{WithSecretToken(secretToken)},
+			ctx:       context.Background(),
+			call:      func(c *Client, ctx context.Context) error { _, err := c.AccountMetadata(ctx); return err },
+			wantToken: secretToken,
+		},
+		{
+			name:      "secret token for usage",
+			opts:      []ClientOption{WithSecretToken(secretToken)},
+			ctx:       context.Background(),
+			call:      func(c *Client, ctx context.Context) error { _, err := c.Usage(ctx); return err },
+			wantToken: secretToken,
+		},
+		{
+			name:      "secret token for pay as you go",
+			opts:      []ClientOption{WithSecretToken(secretToken)},
+			ctx:       context.Background(),
+			call:      func(c *Client, ctx context.Context) error { return c.SetPayAsYouGo(ctx, true) },
+			wantToken: secretToken,
+		},
+		{
+			name:      "falls back to publishable token",
+			ctx:       context.Background(),
+			call:      func(c *Client, ctx context.Context) error { _, err := c.Usage(ctx); return err },
+			wantToken: testToken,
+		},
+		{
+			name:      "context overrides publishable token",
+			opts:      []ClientOption{WithSecretToken(secretToken)},
+			ctx:       ContextWithToken(context.Background(), tenantToken),
+			call:      func(c *Client, ctx context.Context) error { _, err := c.Quote(ctx, "aapl"); return err },
+			wantToken: tenantToken,
+		},
+		{
+			name:      "context overrides secret token",
+			opts:      []ClientOption{WithSecretToken(secretToken)},
+			ctx:       ContextWithToken(context.Background(), tenantToken),
+			call:      func(c *Client, ctx context.Context) error { return c.SetMessageBudget(ctx, 1000) },
+			wantToken: tenantToken,
+		},
+	}
+
+	for _, tc := range testCases {
+		client := NewClient(testToken, append(tc.opts, withBaseAddress(s))...)
+		if err := tc.call(client, tc.ctx); err != nil {
+			t.Fatalf("%s: Error calling endpoint: %s", tc.name, err)
+		}
+		if gotToken != tc.wantToken {
+			t.Errorf("%s: Got token %q, want %q", tc.name, gotToken, tc.wantToken)
+		}
+	}
+}
+
+func TestTokensNotShared(t *testing.T) {
+	tenants := map[string]int{"not-a-real-token-a": 1, "not-a-real-token-b": 2}
+	var requests int32
+	release := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		n, ok := tenants[r.URL.Query().Get("token")]
+		if !ok {
+			http.Error(w, "Test-injected error", http.StatusPaymentRequired)
+			return
+		}
+		fmt.Fprintf(w, `{"monthlyUsage": %d, "latestPrice": %d, "companyName": "Tenant %d"}`, n, n, n)
+	}))
+	defer s.Close()
+	client := NewClient(testToken, withBaseAddress(s), WithRequestCoalescing(), WithCache(NewMemoryCache(10)))
+
+	// Concurrent calls for two tenants must each reach the server and get
+	// their own response.
+	for _, tc := range []struct {
+		name string
+		call func(ctx context.Context) (int, error)
+	}{
+		{"quote", func(ctx context.Context) (int, error) {
+			q, err := client.Quote(ctx, "aapl")
+			return int(q.LatestPrice), err
+		}},
+		{"usage", func(ctx context.Context) (int, error) {
+			u, err := client.Usage(ctx)
+			return u.MonthlyUsage, err
+		}},
+	} {
+		atomic.StoreInt32(&requests, 0)
+		release = make(chan struct{})
+		var wg sync.WaitGroup
+		got := map[string]int{}
+		var mu sync.Mutex
+		for token := range tenants {
+			wg.Add(1)
+			go func(token string) {
+				defer wg.Done()
+				n, err := tc.call(ContextWithToken(context.Background(), token))
+				if err != nil {
+					t.Errorf("%s: Error calling endpoint for %s: %s", tc.name, token, err)
+				}
+				mu.Lock()
+				got[token] = n
+				mu.Unlock()
+			}(token)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for atomic.LoadInt32(&requests) < 2 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if n := atomic.LoadInt32(&requests); n != 2 {
+			t.Errorf("%s: Got %d requests for two tenants, want 2", tc.name, n)
+		}
+		close(release)
+		wg.Wait()
+		for token, want := range tenants {
+			if got[token] != want {
+				t.Errorf("%s: Got %d for %s, want %d", tc.name, got[token], token, want)
+			}
+		}
+	}
+
+	// A tenant whose token is refused must not be served data cached for
+	// another tenant.
+	ctx := ContextWithToken(context.Background(), "not-a-real-token-a")
+	if _, err := client.Company(ctx, "aapl"); err != nil {
+		t.Fatalf("Error getting company: %s", err)
+	}
+	ctx = ContextWithToken(context.Background(), "not-a-real-token-c")
+	if _, err := client.Company(ctx, "aapl"); !errors.Is(err, ErrPaymentRequired) {
+		t.Errorf("Got error %v for a refused token, want %v", err, ErrPaymentRequired)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+// Package tracing instruments an IEX Cloud client with OpenTelemetry traces.
+// Each API call is wrapped in a span, and each connection of an SSE stream
+// gets its own span with one event per batch of data received. Spans are
+// children of the span in the context passed to the client's methods.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	iex "github.com/goinvest/iexcloud/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/goinvest/iexcloud/v2/tracing"
+
+// Attribute keys set on spans.
+const (
+	familyKey              = attribute.Key("iex.endpoint.family")
+	endpointKey            = attribute.Key("iex.endpoint")
+	symbolsKey             = attribute.Key("iex.symbols")
+	statusCodeKey          = attribute.Key("http.response.status_code")
+	messagesUsedKey        = attribute.Key("iex.messages_used")
+	premiumMessagesUsedKey = attribute.Key("iex.premium_messages_used")
+	attemptsKey            = attribute.Key("iex.attempts")
+	rateLimitWaitKey       = attribute.Key("iex.rate_limit_wait_seconds")
+	cachedKey              = attribute.Key("iex.cached")
+	channelKey             = attribute.Key("iex.stream.channel")
+	streamIDKey            = attribute.Key("iex.stream.id")
+	reconnectKey           = attribute.Key("iex.stream.reconnect")
+	batchSizeKey           = attribute.Key("iex.stream.batch_size")
+)
+
+// Tracer creates spans for the IEX Cloud clients it instruments. A single
+// Tracer may instrument several clients.
+type Tracer struct {
+	tracer trace.Tracer
+
+	mu    sync.Mutex
+	conns map[uint64]trace.Span
+}
+
+// New creates a Tracer that uses the given tracer provider. If the provider is
+// nil, the global tracer provider is used.
+func New(tp trace.TracerProvider) *Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &Tracer{
+		tracer: tp.Tracer(instrumentationName),
+		conns:  map[uint64]trace.Span{},
+	}
+}
+
+// Option returns a ClientOption that instruments a new IEX Client with the
+// Tracer.
+func (t *Tracer) Option() iex.ClientOption {
+	return func(client *iex.Client) {
+		iex.WithMiddleware(t.Middleware)(client)
+		iex.WithStreamHook(t.StreamHook)(client)
+	}
+}
+
+// Middleware wraps each request in a span named after its endpoint family.
+func (t *Tracer) Middleware(next iex.Handler) iex.Handler {
+	return func(ctx context.Context, req *iex.Request) (*iex.Response, error) {
+		family := req.Family()
+		attrs := []attribute.KeyValue{
+			familyKey.String(family),
+			endpointKey.String(req.Endpoint),
+		}
+		if symbols := requestSymbols(req); len(symbols) > 0 {
+			attrs = append(attrs, symbolsKey.StringSlice(symbols))
+		}
+		ctx, span := t.tracer.Start(ctx, "iex "+family,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attrs...),
+		)
+		defer span.End()
+
+		resp, err := next(ctx, req)
+		if resp != nil {
+			span.SetAttributes(
+				statusCodeKey.Int(resp.StatusCode),
+				messagesUsedKey.Int(resp.Meta.MessagesUsed),
+				premiumMessagesUsedKey.Int(resp.Meta.PremiumMessagesUsed),
+				attemptsKey.Int(resp.Meta.Attempts),
+				rateLimitWaitKey.Float64(resp.Meta.RateLimitWait.Seconds()),
+				cachedKey.Bool(resp.Meta.Cached),
+			)
+		}
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return resp, err
+	}
+}
+
+// StreamHook creates a span for each connection of an SSE stream. A
+// connection span starts when the stream starts connecting or reconnecting
+// and ends when the connection is lost or the stream ends. Each batch of
+// data received is added to the span as an event.
+func (t *Tracer) StreamHook(ctx context.Context, ev iex.StreamEvent) {
+	switch ev.State {
+	case iex.StreamConnecting:
+		t.startConn(ctx, ev, false)
+	case iex.StreamLive:
+		if span := t.conn(ev.ID); span != nil {
+			if ev.Batch == 0 {
+				span.AddEvent("live")
+			} else {
+				span.AddEvent("batch", trace.WithAttributes(batchSizeKey.Int(ev.Batch)))
+			}
+		}
+	case iex.StreamReconnecting:
+		t.endConn(ev)
+		t.startConn(ctx, ev, true)
+	case iex.StreamClosed, iex.StreamFailed:
+		t.endConn(ev)
+	}
+}
+
+func (t *Tracer) startConn(ctx context.Context, ev iex.StreamEvent, reconnect bool) {
+	_, span := t.tracer.Start(ctx, "iex stream "+ev.Channel,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			channelKey.String(ev.Channel),
+			symbolsKey.StringSlice(ev.Symbols),
+			streamIDKey.Int64(int64(ev.ID)),
+			reconnectKey.Bool(reconnect),
+		),
+	)
+	t.mu.Lock()
+	t.conns[ev.ID] = span
+	t.mu.Unlock()
+}
+
+func (t *Tracer) conn(id uint64) trace.Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.conns[id]
+}
+
+func (t *Tracer) endConn(ev iex.StreamEvent) {
+	t.mu.Lock()
+	span, ok := t.conns[ev.ID]
+	delete(t.conns, ev.ID)
+	t.mu.Unlock()
+	if !ok {
+		return
+	}
+	// Cancelling the context is the normal way to stop a stream.
+	if ev.Err != nil && !errors.Is(ev.Err, context.Canceled) {
+		span.RecordError(ev.Err)
+		span.SetStatus(codes.Error, ev.Err.Error())
+	}
+	span.End()
+}
+
+// requestSymbols returns the symbols a request is for, taken from the path of
+// stock endpoints, such as "/stock/aapl/quote", and from the symbols query
+// parameter used by batch and DEEP endpoints.
+func requestSymbols(req *iex.Request) []string {
+	var symbols []string
+	segments := strings.Split(strings.Trim(req.Endpoint, "/"), "/")
+	if len(segments) >= 2 && segments[0] == "stock" && segments[1] != "market" {
+		symbols = append(symbols, segments[1])
+	}
+	if s := req.Query().Get("symbols"); s != "" {
+		symbols = append(symbols, strings.Split(s, ",")...)
+	}
+	return symbols
+}
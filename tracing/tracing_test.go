@@ -0,0 +1,189 @@
+// Copyright (c) 2019-2024 The iexcloud developers. All rights reserved.
+// Project site: https://github.com/goinvest/iexcloud
+// Use of this source code is governed by a MIT-style license that
+// can be found in the LICENSE file for the project.
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	iex "github.com/goinvest/iexcloud/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const testToken = "not-a-real-token"
+
+func newTestTracer() (*Tracer, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	return New(tp), recorder
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestMiddlewareSpans(t *testing.T) {
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stock/aapl/quote" {
+			http.Error(w, "Test-injected error", http.StatusPaymentRequired)
+			return
+		}
+		if atomic.AddInt32(&requests, 1) == 1 {
+			http.Error(w, "Test-injected error", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("iexcloud-messages-used", "2")
+		w.Write([]byte("42.5"))
+	}))
+	defer s.Close()
+
+	tracer, recorder := newTestTracer()
+	client := iex.NewClient(testToken,
+		iex.WithBaseURL(s.URL),
+		iex.WithRetryPolicy(iex.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}),
+		tracer.Option(),
+	)
+
+	parentTracer := sdktrace.NewTracerProvider().Tracer("test")
+	ctx, parent := parentTracer.Start(context.Background(), "parent")
+	if _, err := client.Price(ctx, "aapl"); err != nil {
+		t.Fatalf("Error getting price: %s", err)
+	}
+	if _, err := client.Quote(ctx, "aapl"); err == nil {
+		t.Fatal("Got nil error getting quote, want error")
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Got %d spans, want 2", len(spans))
+	}
+	price, quote := spans[0], spans[1]
+	if got, want := price.Name(), "iex price"; got != want {
+		t.Errorf("Got span name %q, want %q", got, want)
+	}
+	if got, want := price.Parent().SpanID(), parent.SpanContext().SpanID(); got != want {
+		t.Errorf("Got parent span %s, want %s", got, want)
+	}
+	got := attributes(price)
+	want := map[attribute.Key]attribute.Value{
+		familyKey:              attribute.StringValue("price"),
+		endpointKey:            attribute.StringValue("/stock/aapl/price"),
+		symbolsKey:             attribute.StringSliceValue([]string{"aapl"}),
+		statusCodeKey:          attribute.IntValue(200),
+		messagesUsedKey:        attribute.IntValue(2),
+		premiumMessagesUsedKey: attribute.IntValue(0),
+		attemptsKey:            attribute.IntValue(2),
+		cachedKey:              attribute.BoolValue(false),
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("Got %s = %v, want %v", k, got[k].Emit(), v.Emit())
+		}
+	}
+	if _, ok := got[rateLimitWaitKey]; !ok {
+		t.Errorf("Got no %s attribute", rateLimitWaitKey)
+	}
+	if got, want := quote.Status().Code, codes.Error; got != want {
+		t.Errorf("Got status %v, want %v", got, want)
+	}
+	if got, want := attributes(quote)[statusCodeKey], attribute.IntValue(402); got != want {
+		t.Errorf("Got status code %v, want %v", got.Emit(), want.Emit())
+	}
+}
+
+func TestStreamHookSpans(t *testing.T) {
+	tracer, recorder := newTestTracer()
+	ctx := context.Background()
+	ev := func(state iex.StreamState, batch int, err error) iex.StreamEvent {
+		return iex.StreamEvent{
+			ID:      7,
+			Channel: "stocksUS",
+			Symbols: []string{"aapl"},
+			State:   state,
+			Batch:   batch,
+			Err:     err,
+		}
+	}
+	for _, e := range []iex.StreamEvent{
+		ev(iex.StreamConnecting, 0, nil),
+		ev(iex.StreamLive, 0, nil),
+		ev(iex.StreamLive, 2, nil),
+		ev(iex.StreamLive, 1, nil),
+		ev(iex.StreamReconnecting, 0, errors.New("connection reset")),
+		ev(iex.StreamLive, 0, nil),
+		ev(iex.StreamLive, 3, nil),
+		ev(iex.StreamClosed, 0, context.Canceled),
+	} {
+		tracer.StreamHook(ctx, e)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Got %d spans, want 2", len(spans))
+	}
+	type summary struct {
+		Name      string
+		Reconnect bool
+		Events    []string
+		Status    codes.Code
+	}
+	var got []summary
+	for _, span := range spans {
+		s := summary{
+			Name:      span.Name(),
+			Reconnect: attributes(span)[reconnectKey].AsBool(),
+			Status:    span.Status().Code,
+		}
+		for _, e := range span.Events() {
+			s.Events = append(s.Events, e.Name)
+		}
+		got = append(got, s)
+	}
+	want := []summary{
+		{"iex stream stocksUS", false, []string{"live", "batch", "batch", "exception"}, codes.Error},
+		{"iex stream stocksUS", true, []string{"live", "batch"}, codes.Unset},
+	}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestRequestSymbols(t *testing.T) {
+	for _, tc := range []struct {
+		endpoint string
+		query    string
+		want     []string
+	}{
+		{"/stock/aapl/quote", "", []string{"aapl"}},
+		{"/stock/market/batch", "symbols=aapl,goog&types=quote", []string{"aapl", "goog"}},
+		{"/deep/book", "symbols=spy", []string{"spy"}},
+		{"/ref-data/symbols", "", nil},
+	} {
+		req := &iex.Request{
+			Endpoint: tc.endpoint,
+			URL:      &url.URL{Path: tc.endpoint, RawQuery: tc.query},
+		}
+		if diff := deep.Equal(requestSymbols(req), tc.want); diff != nil {
+			t.Errorf("%s: %v", tc.endpoint, diff)
+		}
+	}
+}